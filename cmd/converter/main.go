@@ -8,6 +8,7 @@ import (
 
 	"backup-plan-ui/converter"
 	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
 )
 
 func usage() {
@@ -15,7 +16,21 @@ func usage() {
 	fmt.Println("Usage:")
 	fmt.Printf("  %s sqlite <path-to-csv> <path-to-sqlite>\n", prog)
 	fmt.Printf("  %s mysql <path-to-csv> [table-name]\n", prog)
+	fmt.Printf("  %s postgres <path-to-csv> [table-name]\n", prog)
 	fmt.Println("\nEnvironment (mysql): MYSQL_HOST, MYSQL_PORT, MYSQL_USER, MYSQL_PASS, MYSQL_DATABASE")
+	fmt.Println("Environment (postgres): PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE")
+	fmt.Println("Environment (all): BACKUP_PLAN_UI_VALIDATION_POLICY")
+}
+
+// policyFromEnv loads the validation policy in the file named by the
+// BACKUP_PLAN_UI_VALIDATION_POLICY environment variable, if it is set.
+func policyFromEnv() (*validation.Policy, error) {
+	path := os.Getenv("BACKUP_PLAN_UI_VALIDATION_POLICY")
+	if path == "" {
+		return nil, nil
+	}
+
+	return validation.LoadPolicy(path)
 }
 
 func main() {
@@ -24,6 +39,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	policy, err := policyFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	mode := os.Args[1]
 	switch mode {
 	case "sqlite":
@@ -34,7 +54,7 @@ func main() {
 
 		csvPath := os.Args[2]
 		sqlitePath := os.Args[3]
-		if err := converter.ConvertCsvToSqlite(csvPath, sqlitePath); err != nil {
+		if err := converter.ConvertCsvToSqlite(csvPath, sqlitePath, policy); err != nil {
 			log.Fatalf("Conversion failed: %v", err)
 		}
 
@@ -56,7 +76,29 @@ func main() {
 		pass := os.Getenv("MYSQL_PASS")
 		db := os.Getenv("MYSQL_DATABASE")
 
-		if err := converter.ConvertCsvToMySQL(csvPath, host, port, user, pass, db, tableName); err != nil {
+		if err := converter.ConvertCsvToMySQL(csvPath, host, port, user, pass, db, tableName, policy); err != nil {
+			log.Fatalf("Conversion failed: %v", err)
+		}
+
+	case "postgres":
+		if len(os.Args) < 3 || len(os.Args) > 4 {
+			usage()
+			os.Exit(1)
+		}
+
+		csvPath := os.Args[2]
+		tableName := sources.DefaultTableName
+		if len(os.Args) == 4 && os.Args[3] != "" {
+			tableName = os.Args[3]
+		}
+
+		host := os.Getenv("PGHOST")
+		port := os.Getenv("PGPORT")
+		user := os.Getenv("PGUSER")
+		pass := os.Getenv("PGPASSWORD")
+		db := os.Getenv("PGDATABASE")
+
+		if err := converter.ConvertCsvToPostgres(csvPath, host, port, user, pass, db, tableName, policy); err != nil {
 			log.Fatalf("Conversion failed: %v", err)
 		}
 
@@ -8,24 +8,17 @@ import (
 	"strings"
 
 	. "backup-plan-ui/sources"
+	"backup-plan-ui/validation"
 )
 
 var ErrWrongEntry = errors.New("wrong entry")
 
-func ConvertCsvToSqlite(csvPath, sqlitePath string) error {
-	csv := CSVSource{Path: csvPath}
-	entries, err := csv.ReadAll()
+func ConvertCsvToSqlite(csvPath, sqlitePath string, policy *validation.Policy) error {
+	entries, err := readValidEntries(csvPath, policy)
 	if err != nil {
 		return err
 	}
 
-	for _, e := range entries {
-		err = fixEntry(e)
-		if err != nil {
-			return err
-		}
-	}
-
 	sq, err := NewSQLiteSource(sqlitePath)
 	if err != nil {
 		return err
@@ -46,47 +39,73 @@ func ConvertCsvToSqlite(csvPath, sqlitePath string) error {
 	return sq.WriteEntries(entries)
 }
 
-func fixEntry(e *Entry) error {
-	e.Instruction = Instruction(strings.Trim(string(e.Instruction), " "))
+// readValidEntries returns the entries in the CSV file, tidied up, or an error
+// wrapping the validation.EntryError of every entry that breaks the policy, as
+// the UI would reject them.
+func readValidEntries(csvPath string, policy *validation.Policy) ([]*Entry, error) {
+	csv := CSVSource{Path: csvPath}
 
-	if e.Instruction != Backup && e.Instruction != NoBackup && e.Instruction != TempBackup {
-		return fmt.Errorf("%w: invalid instruction for entry %+v", ErrWrongEntry, e)
+	entries, err := csv.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		fixEntry(e)
 	}
 
+	if err = policy.Plan(entries); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWrongEntry, err)
+	}
+
+	return entries, nil
+}
+
+// fixEntry removes the stray spaces that spreadsheets tend to leave around
+// values.
+func fixEntry(e *Entry) {
+	e.Instruction = Instruction(strings.Trim(string(e.Instruction), " "))
 	e.Match = strings.Trim(e.Match, " ")
 	e.Ignore = strings.Trim(e.Ignore, " ")
 	e.Requestor = strings.Trim(e.Requestor, " ")
 	e.Faculty = strings.Trim(e.Faculty, " ")
-
-	return nil
 }
 
-func ConvertCsvToMySQL(csvPath, host, port, user, password, database, tableName string) error {
-	csv := CSVSource{Path: csvPath}
-	entries, err := csv.ReadAll()
+func ConvertCsvToMySQL(csvPath, host, port, user, password, database, tableName string,
+	policy *validation.Policy) error {
+	sq, err := NewMySQLSource(host, port, user, password, database, tableName)
 	if err != nil {
 		return err
 	}
 
-	for _, e := range entries {
-		err = fixEntry(e)
-		if err != nil {
-			return err
-		}
-	}
+	return convertCsvToServer(csvPath, sq.SQLSource, tableName, "MySQL", policy)
+}
 
-	sq, err := NewMySQLSource(host, port, user, password, database, tableName)
+func ConvertCsvToPostgres(csvPath, host, port, user, password, database, tableName string,
+	policy *validation.Policy) error {
+	sq, err := NewPostgresSource(host, port, user, password, database, tableName)
 	if err != nil {
 		return err
 	}
 
+	return convertCsvToServer(csvPath, sq.SQLSource, tableName, "PostgreSQL", policy)
+}
+
+// convertCsvToServer replaces the table in a database server with the entries
+// in the CSV file, closing the connection afterward.
+func convertCsvToServer(csvPath string, sq *SQLSource, tableName, dbType string, policy *validation.Policy) error {
 	defer func() {
-		err = sq.Close()
+		err := sq.Close()
 		if err != nil {
-			slog.Error("Failed to close MySQL connection: " + err.Error())
+			slog.Error("Failed to close " + dbType + " connection: " + err.Error())
 		}
 	}()
 
+	entries, err := readValidEntries(csvPath, policy)
+	if err != nil {
+		return err
+	}
+
 	tables, err := sq.ShowTables()
 	if err != nil {
 		return err
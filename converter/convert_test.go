@@ -1,12 +1,14 @@
 package converter
 
 import (
+	"bytes"
 	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
 
 	. "github.com/smarty/assertions"
 )
@@ -15,7 +17,7 @@ func TestConvertCsvToSqlite(t *testing.T) {
 	entries, csvPath := sources.CreateTestCSV(t)
 	sqlitePath := filepath.Join(t.TempDir(), "test.sqlite")
 
-	err := ConvertCsvToSqlite(csvPath, sqlitePath)
+	err := ConvertCsvToSqlite(csvPath, sqlitePath, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,6 +48,43 @@ func TestConvertCsvToSqlite(t *testing.T) {
 	}
 }
 
+func TestConvertCsvToSqlite_InvalidEntries(t *testing.T) {
+	_, csvPath := sources.CreateTestCSV(t)
+
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data = bytes.Replace(data, []byte(",backup,"), []byte(",keep,"), 1)
+
+	if err = os.WriteFile(csvPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sqlitePath := filepath.Join(t.TempDir(), "test.sqlite")
+
+	err = ConvertCsvToSqlite(csvPath, sqlitePath, nil)
+	if !errors.Is(err, ErrWrongEntry) {
+		t.Fatalf("expected %v, got %v", ErrWrongEntry, err)
+	}
+
+	var entryErr *validation.EntryError
+	if !errors.As(err, &entryErr) {
+		t.Fatalf("expected an EntryError, got %v", err)
+	}
+
+	if ok, err := So(entryErr.Fields, ShouldResemble, validation.Errors{
+		validation.Instruction: "Input must be backup, nobackup or tempbackup",
+	}); !ok {
+		t.Error(err)
+	}
+
+	if _, err = os.Stat(sqlitePath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no database to be created, got %v", err)
+	}
+}
+
 func TestConvertCsvToMySQL(t *testing.T) {
 	entries, csvPath := sources.CreateTestCSV(t)
 
@@ -87,6 +126,7 @@ func TestConvertCsvToMySQL(t *testing.T) {
 		os.Getenv("MYSQL_PASS"),
 		os.Getenv("MYSQL_DATABASE"),
 		tableName,
+		nil,
 	)
 
 	newEntries, err := sq.ReadAll()
@@ -102,3 +142,138 @@ func TestConvertCsvToMySQL(t *testing.T) {
 		t.Error(e)
 	}
 }
+
+func TestConvertCsvToPostgres(t *testing.T) {
+	entries, csvPath := sources.CreateTestCSV(t)
+
+	tableName := "test_convert"
+
+	sq, err := sources.NewPostgresSource(
+		os.Getenv("PGHOST"),
+		os.Getenv("PGPORT"),
+		os.Getenv("PGUSER"),
+		os.Getenv("PGPASSWORD"),
+		os.Getenv("PGDATABASE"),
+		tableName,
+	)
+	if err != nil {
+		if errors.Is(err, sources.ErrMissingArgument) {
+			t.Skip("Skipping PostgreSQL test because PostgreSQL host, port, user, or database is not set.")
+		}
+
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err = sq.DropTable()
+		if err != nil {
+			t.Log(err)
+		}
+
+		err = sq.Close()
+		if err != nil {
+			t.Log(err)
+		}
+	})
+
+	err = ConvertCsvToPostgres(
+		csvPath,
+		os.Getenv("PGHOST"),
+		os.Getenv("PGPORT"),
+		os.Getenv("PGUSER"),
+		os.Getenv("PGPASSWORD"),
+		os.Getenv("PGDATABASE"),
+		tableName,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEntries, err := sq.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		entry.ID += 1
+	}
+
+	if ok, e := So(newEntries, ShouldResemble, entries); !ok {
+		t.Error(e)
+	}
+}
+
+func TestConvertCsvToServerKeepsHistoryAndSnapshots(t *testing.T) {
+	_, csvPath := sources.CreateTestCSV(t)
+	sqlitePath := filepath.Join(t.TempDir(), "test.sqlite")
+
+	convert := func() {
+		t.Helper()
+
+		sq, err := sources.NewSQLiteSource(sqlitePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = convertCsvToServer(csvPath, sq.SQLSource, sources.DefaultTableName, "SQLite", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	open := func() sources.SQLiteSource {
+		t.Helper()
+
+		sq, err := sources.NewSQLiteSource(sqlitePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			if err = sq.Close(); err != nil {
+				t.Log(err)
+			}
+		})
+
+		return sq
+	}
+
+	convert()
+
+	sq := open()
+
+	entries, err := sq.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sq.AddHistory(sources.NewHistoryRecord(sources.OpAdd, "alice", nil, entries[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = sq.PublishSnapshot("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	convert()
+
+	sq = open()
+
+	history, err := sq.GetHistory(entries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, e := So(history, ShouldHaveLength, 1); !ok {
+		t.Error(e)
+	}
+
+	snapshots, err := sq.ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, e := So(snapshots, ShouldHaveLength, 1); !ok {
+		t.Error(e)
+	}
+}
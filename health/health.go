@@ -0,0 +1,184 @@
+// Package health analyses the plan as a whole for rules that contradict or
+// repeat each other, which checking one entry at a time cannot find.
+package health
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+
+	"backup-plan-ui/resolver"
+	"backup-plan-ui/sources"
+)
+
+// Kind is the kind of problem an Issue describes.
+type Kind string
+
+const (
+	// Duplicate means another entry has the same Directory.
+	Duplicate Kind = "duplicate"
+
+	// Redundant means the entry has the same instruction and patterns as its
+	// parent, so removing it would change nothing.
+	Redundant Kind = "redundant"
+
+	// Contradiction means the entry's instruction is at odds with its parent's.
+	Contradiction Kind = "contradiction"
+
+	// ReportingRootMismatch means the entry has a different ReportingRoot than
+	// its parent.
+	ReportingRootMismatch Kind = "reporting_root_mismatch"
+
+	// UnreachableMatch means one of the entry's Match patterns only matches
+	// paths that its Ignore patterns exclude.
+	UnreachableMatch Kind = "unreachable_match"
+)
+
+// Issue is a problem with an Entry of the plan, caused by the Other entry: its
+// parent, or the first entry with the same directory for duplicates. Other is
+// nil for problems with the entry on its own.
+type Issue struct {
+	Kind    Kind           `json:"kind"`
+	Entry   *sources.Entry `json:"entry"`
+	Other   *sources.Entry `json:"other,omitempty"`
+	Message string         `json:"message"`
+}
+
+// contradictions describes the instructions of child entries that are at odds
+// with the instruction of their parent.
+var contradictions = map[sources.Instruction]map[sources.Instruction]string{
+	sources.TempBackup: {
+		sources.NoBackup: "excludes part of the temporary backup of",
+		sources.Backup:   "permanently backs up part of the temporary backup of",
+	},
+}
+
+// Check returns the issues with the given entries, ordered by the ID of the
+// entry they are about.
+func Check(entries []*sources.Entry) []Issue {
+	sorted := slices.Clone(entries)
+	slices.SortFunc(sorted, func(a, b *sources.Entry) int { return cmp.Compare(a.ID, b.ID) })
+
+	firstForDir := make(map[string]*sources.Entry, len(sorted))
+
+	for _, entry := range sorted {
+		if dir := path.Clean(entry.Directory); firstForDir[dir] == nil {
+			firstForDir[dir] = entry
+		}
+	}
+
+	var issues []Issue
+
+	for _, entry := range sorted {
+		dir := path.Clean(entry.Directory)
+
+		if first := firstForDir[dir]; first != entry {
+			issues = append(issues, Issue{
+				Kind:    Duplicate,
+				Entry:   entry,
+				Other:   first,
+				Message: fmt.Sprintf("%s already has an entry (%s)", dir, first.ReportingName),
+			})
+		}
+
+		issues = append(issues, checkPatterns(entry)...)
+
+		if parent := parentIn(firstForDir, dir); parent != nil {
+			issues = append(issues, checkNested(entry, parent)...)
+		}
+	}
+
+	return issues
+}
+
+// parentIn returns the entry governing the clean directory dir, as
+// resolver.Parent does, looking up each directory above it in firstForDir
+// rather than going through every entry. Relative directories are only inside
+// the root.
+func parentIn(firstForDir map[string]*sources.Entry, dir string) *sources.Entry {
+	for dir != "/" {
+		if dir = path.Dir(dir); !path.IsAbs(dir) {
+			dir = "/"
+		}
+
+		if parent, ok := firstForDir[dir]; ok {
+			return parent
+		}
+	}
+
+	return nil
+}
+
+// checkNested returns the issues with an entry nested in its parent.
+func checkNested(entry, parent *sources.Entry) []Issue {
+	var issues []Issue
+
+	add := func(kind Kind, format string, args ...any) {
+		issues = append(issues, Issue{Kind: kind, Entry: entry, Other: parent, Message: fmt.Sprintf(format, args...)})
+	}
+
+	dir, parentDir := path.Clean(entry.Directory), path.Clean(parent.Directory)
+
+	if entry.Instruction == parent.Instruction && samePatterns(entry.Match, parent.Match) &&
+		samePatterns(entry.Ignore, parent.Ignore) {
+		add(Redundant, "%s repeats the %s instruction of %s", dir, entry.Instruction, parentDir)
+	}
+
+	if reason, ok := contradictions[parent.Instruction][entry.Instruction]; ok {
+		add(Contradiction, "%s (%s) %s %s", dir, entry.Instruction, reason, parentDir)
+	}
+
+	if entry.ReportingRoot != parent.ReportingRoot {
+		add(ReportingRootMismatch, "%s is reported under %s, but is inside %s which is reported under %s",
+			dir, entry.ReportingRoot, parentDir, parent.ReportingRoot)
+	}
+
+	return issues
+}
+
+// checkPatterns returns the issues with the Match and Ignore patterns of an entry.
+func checkPatterns(entry *sources.Entry) []Issue {
+	match := resolver.SplitPatterns(entry.Match)
+	unreachable := resolver.Unreachable(match, resolver.SplitPatterns(entry.Ignore))
+
+	var issues []Issue
+
+	for _, m := range match {
+		i, ok := unreachable[m]
+		if !ok {
+			continue
+		}
+
+		message := fmt.Sprintf("the match pattern %s never backs anything up, as the ignore pattern %s "+
+			"excludes everything it matches", m, i)
+
+		issues = append(issues, Issue{Kind: UnreachableMatch, Entry: entry, Message: message})
+	}
+
+	return issues
+}
+
+// samePatterns returns true if the space separated patterns a and b contain the
+// same patterns, in any order.
+func samePatterns(a, b string) bool {
+	pa, pb := resolver.SplitPatterns(a), resolver.SplitPatterns(b)
+
+	slices.Sort(pa)
+	slices.Sort(pb)
+
+	return slices.Equal(pa, pb)
+}
+
+// ForEntry returns the issues about, or caused by, the entry with the given ID.
+func ForEntry(issues []Issue, id uint64) []Issue {
+	var matching []Issue
+
+	for _, issue := range issues {
+		if issue.Entry.ID == id || (issue.Other != nil && issue.Other.ID == id) {
+			matching = append(matching, issue)
+		}
+	}
+
+	return matching
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestCheck(t *testing.T) {
+	root := "/lustre/projects/a"
+
+	project := &sources.Entry{ID: 1, ReportingRoot: root, Directory: root, Instruction: sources.TempBackup}
+	excluded := &sources.Entry{ID: 2, ReportingRoot: root, Directory: root + "/tmp", Instruction: sources.NoBackup}
+	repeated := &sources.Entry{ID: 3, ReportingRoot: root, Directory: root + "/tmp/more/",
+		Instruction: sources.NoBackup}
+	otherRoot := &sources.Entry{ID: 4, ReportingRoot: "/lustre/projects", Directory: root + "/input",
+		Instruction: sources.TempBackup}
+	duplicate := &sources.Entry{ID: 5, ReportingRoot: root, Directory: root + "/tmp/", Instruction: sources.NoBackup}
+	patterns := &sources.Entry{ID: 6, ReportingRoot: root, Directory: root + "/data", Instruction: sources.Backup,
+		Ignore: "*.log *.tmp"}
+	samePatterns := &sources.Entry{ID: 7, ReportingRoot: root, Directory: root + "/data/x", Instruction: sources.Backup,
+		Ignore: "*.tmp *.log"}
+	fewerPatterns := &sources.Entry{ID: 8, ReportingRoot: root, Directory: root + "/data/y",
+		Instruction: sources.Backup, Ignore: "*.tmp"}
+	unreachable := &sources.Entry{ID: 9, ReportingRoot: "/lustre/other", Directory: "/lustre/other",
+		Instruction: sources.Backup, Match: "*.cram tmp/*.bam", Ignore: "*.cram"}
+
+	entries := []*sources.Entry{
+		unreachable, fewerPatterns, samePatterns, patterns, duplicate, otherRoot, repeated, excluded, project,
+	}
+
+	issues := Check(entries)
+
+	type found struct {
+		Kind  Kind
+		Entry uint64
+		Other uint64
+	}
+
+	var got []found
+
+	for _, issue := range issues {
+		f := found{Kind: issue.Kind, Entry: issue.Entry.ID}
+		if issue.Other != nil {
+			f.Other = issue.Other.ID
+		}
+
+		got = append(got, f)
+
+		if ok, err := So(issue.Message, ShouldNotBeBlank); !ok {
+			t.Error(err)
+		}
+	}
+
+	expected := []found{
+		{Contradiction, 2, 1},
+		{Redundant, 3, 2},
+		{Redundant, 4, 1},
+		{ReportingRootMismatch, 4, 1},
+		{Duplicate, 5, 2},
+		{Contradiction, 5, 1},
+		{Contradiction, 6, 1},
+		{Redundant, 7, 6},
+		{UnreachableMatch, 9, 0},
+	}
+
+	if ok, err := So(got, ShouldResemble, expected); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(ForEntry(issues, 1), ShouldHaveLength, 5); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(Check([]*sources.Entry{project}), ShouldBeEmpty); !ok {
+		t.Error(err)
+	}
+}
+
+func TestCheckManyEntries(t *testing.T) {
+	const n = 20000
+
+	root := &sources.Entry{ID: 1, ReportingRoot: "/lustre", Directory: "/lustre", Instruction: sources.TempBackup}
+	entries := []*sources.Entry{root}
+
+	for i := range n {
+		entries = append(entries, &sources.Entry{ID: uint64(i + 2), ReportingRoot: "/lustre",
+			Directory: fmt.Sprintf("/lustre/projects/%d", i), Instruction: sources.NoBackup})
+	}
+
+	start := time.Now()
+	issues := Check(entries)
+
+	if ok, err := So(time.Since(start), ShouldBeLessThan, time.Second); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(issues, ShouldHaveLength, n); !ok {
+		t.Fatal(err)
+	}
+
+	for _, issue := range issues {
+		if issue.Kind != Contradiction || issue.Other != root {
+			t.Fatalf("expected every entry to contradict the root, got %+v", issue)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Package importer compares a plan uploaded as a CSV file with the live plan,
+// so the changes importing it would make can be reviewed before any are made.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
+
+	"github.com/gocarina/gocsv"
+)
+
+// firstRow is the number of the first row of entries in a spreadsheet, after
+// the header.
+const firstRow = 2
+
+var (
+	ErrNoRows = errors.New("the file has no entries")
+
+	// ErrNotInDiff is returned when accepting a change that is not in the diff,
+	// usually because the plan has changed since the diff was shown.
+	ErrNotInDiff = errors.New("change is no longer in the diff, check the file again")
+
+	ErrRejected = errors.New("change has errors")
+)
+
+// csvRow is a row of an uploaded file. It has the columns of the CSV backend,
+// but keeps the ID and version as text to tell if they were given.
+type csvRow struct {
+	ReportingName string `csv:"reporting_name"`
+	ReportingRoot string `csv:"reporting_root"`
+	Directory     string `csv:"directory"`
+	Instruction   string `csv:"instruction"`
+	Match         string `csv:"match"`
+	Ignore        string `csv:"ignore"`
+	Requestor     string `csv:"requestor"`
+	Faculty       string `csv:"faculty"`
+	ID            string `csv:"id"`
+	Version       string `csv:"version"`
+	DeletedAt     string `csv:"deleted_at"`
+}
+
+// Row is an entry read from an uploaded file.
+type Row struct {
+	// Number is the number of the row in a spreadsheet, the header being 1.
+	Number int
+	Entry  *sources.Entry
+
+	// HasID and HasVersion are true if the row gave the ID and version of the
+	// entry it changes.
+	HasID      bool
+	HasVersion bool
+
+	// Errors are the problems reading the row.
+	Errors []string
+}
+
+// Parse reads the rows of a CSV file with the columns of the CSV backend, such
+// as an export of the plan. Every column but directory may be left out, and
+// surrounding spaces are removed from every value. Rows of deleted entries are
+// skipped.
+func Parse(r io.Reader) ([]*Row, error) {
+	var records []*csvRow
+
+	if err := gocsv.Unmarshal(r, &records); err != nil {
+		if errors.Is(err, gocsv.ErrEmptyCSVFile) {
+			return nil, ErrNoRows
+		}
+
+		return nil, err
+	}
+
+	rows := make([]*Row, 0, len(records))
+
+	for i, record := range records {
+		if strings.TrimSpace(record.DeletedAt) != "" {
+			continue
+		}
+
+		rows = append(rows, record.toRow(i+firstRow))
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return rows, nil
+}
+
+func (c *csvRow) toRow(number int) *Row {
+	row := &Row{
+		Number: number,
+		Entry: &sources.Entry{
+			ReportingName: strings.TrimSpace(c.ReportingName),
+			ReportingRoot: strings.TrimSpace(c.ReportingRoot),
+			Directory:     strings.TrimSpace(c.Directory),
+			Instruction:   sources.Instruction(strings.TrimSpace(c.Instruction)),
+			Match:         strings.TrimSpace(c.Match),
+			Ignore:        strings.TrimSpace(c.Ignore),
+			Requestor:     strings.TrimSpace(c.Requestor),
+			Faculty:       strings.TrimSpace(c.Faculty),
+		},
+	}
+
+	if id := strings.TrimSpace(c.ID); id != "" {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("ID %q is not a number", id))
+		}
+
+		row.Entry.ID, row.HasID = n, err == nil
+	}
+
+	if version := strings.TrimSpace(c.Version); version != "" {
+		n, err := strconv.ParseUint(version, 10, 32)
+		if err != nil {
+			row.Errors = append(row.Errors, fmt.Sprintf("Version %q is not a number", version))
+		}
+
+		row.Entry.Version, row.HasVersion = uint32(n), err == nil
+	}
+
+	return row
+}
+
+// Change is a change importing a file would make to the plan.
+type Change struct {
+	Kind sources.ChangeKind
+
+	// Row is the number of the row making the change, 0 for removals.
+	Row int
+
+	// Before is the live entry, nil for additions. After is the entry as it
+	// would be, nil for removals.
+	Before *sources.Entry
+	After  *sources.Entry
+
+	// Errors are the reasons the change cannot be made.
+	Errors []string
+}
+
+// Key identifies the change, and the version of the entry it changes, between
+// comparisons of the same file.
+func (c *Change) Key() string {
+	if c.Kind == sources.ChangeAdd {
+		return fmt.Sprintf("%s-%d", c.Kind, c.Row)
+	}
+
+	return fmt.Sprintf("%s-%d-%d", c.Kind, c.Before.ID, c.Before.Version)
+}
+
+// Entry returns the entry the change is about: as it would be, or as it was
+// for removals.
+func (c *Change) Entry() *sources.Entry {
+	if c.After == nil {
+		return c.Before
+	}
+
+	return c.After
+}
+
+// Diff is the difference between an uploaded file and the live plan.
+type Diff struct {
+	// Changes are the changes the rows make, in the order of the file, then the
+	// removals.
+	Changes []*Change
+
+	// Unchanged is how many rows match their entry exactly.
+	Unchanged int
+}
+
+// Compare works out the changes the rows make to the live entries. A row with
+// an ID changes the entry with that ID, and a row without one changes the entry
+// with the same directory, or adds a new entry if there is none. With
+// removeMissing, the live entries no row changes are removed. Updates keep the
+// requestor of the live entry.
+//
+// Changes that cannot be made, because their entry breaks the policy, their ID
+// or directory match the wrong number of entries, or their version is not the
+// live one, have Errors.
+func Compare(rows []*Row, live []*sources.Entry, removeMissing bool, policy *validation.Policy) *Diff {
+	byID := make(map[uint64]*sources.Entry, len(live))
+	byDirectory := make(map[string][]*sources.Entry, len(live))
+
+	for _, entry := range live {
+		byID[entry.ID] = entry
+		dir := path.Clean(entry.Directory)
+		byDirectory[dir] = append(byDirectory[dir], entry)
+	}
+
+	diff := &Diff{}
+	changedBy := make(map[uint64]int)
+	addedBy := make(map[string]int)
+
+	for _, row := range rows {
+		change := &Change{Kind: sources.ChangeAdd, Row: row.Number, Errors: slices.Clone(row.Errors)}
+		dir := path.Clean(row.Entry.Directory)
+
+		switch matches := byDirectory[dir]; {
+		case row.HasID:
+			change.Before = byID[row.Entry.ID]
+			if change.Before == nil {
+				change.addError("There is no entry with ID %d, leave the ID blank to add one", row.Entry.ID)
+			}
+		case len(matches) == 1:
+			change.Before = matches[0]
+		case len(matches) > 1:
+			change.addError("%d entries have this directory, give the ID of the one to change", len(matches))
+		}
+
+		if change.Before == nil {
+			change.After = added(row.Entry)
+
+			if other, ok := addedBy[dir]; ok && row.Entry.Directory != "" {
+				change.addError("Row %d adds an entry for the same directory", other)
+			} else {
+				addedBy[dir] = row.Number
+			}
+		} else {
+			change.Kind = sources.ChangeUpdate
+			change.After = updated(row.Entry, change.Before)
+
+			_, taken := changedBy[change.Before.ID]
+
+			if *change.After == *change.Before && len(change.Errors) == 0 && !taken {
+				diff.Unchanged++
+				changedBy[change.Before.ID] = row.Number
+
+				continue
+			}
+
+			change.checkUpdate(row, changedBy)
+		}
+
+		change.addValidationErrors(policy)
+		diff.Changes = append(diff.Changes, change)
+	}
+
+	if removeMissing {
+		for _, entry := range live {
+			if _, ok := changedBy[entry.ID]; !ok {
+				diff.Changes = append(diff.Changes, &Change{Kind: sources.ChangeRemove, Before: entry})
+			}
+		}
+	}
+
+	return diff
+}
+
+// added returns the entry a row adds.
+func added(entry *sources.Entry) *sources.Entry {
+	after := *entry
+	after.ID = 0
+	after.Version = 0
+
+	return &after
+}
+
+// updated returns the entry as the row would change it, with the ID and version
+// of the live entry so it is only changed if it is still at that version. The
+// requestor of the live entry is kept, as a file cannot say who requested it.
+func updated(entry, before *sources.Entry) *sources.Entry {
+	after := *entry
+	after.ID = before.ID
+	after.Version = before.Version
+	after.Requestor = before.Requestor
+	after.DeletedAt = nil
+
+	return &after
+}
+
+// checkUpdate rejects an update to an entry that another row already changes,
+// or that has changed since the file was exported.
+func (c *Change) checkUpdate(row *Row, changedBy map[uint64]int) {
+	if other, ok := changedBy[c.Before.ID]; ok {
+		c.addError("Row %d also changes entry %d", other, c.Before.ID)
+	} else {
+		changedBy[c.Before.ID] = row.Number
+	}
+
+	if row.HasVersion && row.Entry.Version != c.Before.Version {
+		c.addError("Entry %d has been changed since this file was exported", c.Before.ID)
+	}
+}
+
+func (c *Change) addValidationErrors(policy *validation.Policy) {
+	errs := policy.Validate(c.After)
+
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, string(field))
+	}
+
+	slices.Sort(fields)
+
+	for _, field := range fields {
+		c.addError("%s: %s", field, errs[validation.Field(field)])
+	}
+}
+
+func (c *Change) addError(format string, args ...any) {
+	c.Errors = append(c.Errors, fmt.Sprintf(format, args...))
+}
+
+// Accept returns the changes with the given keys, and a ChangeSet making them.
+// It returns ErrNotInDiff if a key is not in the diff, and ErrRejected if a
+// change with a key has errors.
+func (d *Diff) Accept(keys []string) (*sources.ChangeSet, []*Change, error) {
+	byKey := make(map[string]*Change, len(d.Changes))
+	for _, change := range d.Changes {
+		byKey[change.Key()] = change
+	}
+
+	set := &sources.ChangeSet{}
+	accepted := make([]*Change, 0, len(keys))
+
+	for _, key := range keys {
+		change, ok := byKey[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNotInDiff, key)
+		}
+
+		if slices.Contains(accepted, change) {
+			continue
+		}
+
+		if len(change.Errors) > 0 {
+			return nil, nil, fmt.Errorf("%w: %s: %s", ErrRejected, key, strings.Join(change.Errors, "; "))
+		}
+
+		switch change.Kind {
+		case sources.ChangeAdd:
+			set.Add = append(set.Add, change.After)
+		case sources.ChangeUpdate:
+			set.Update = append(set.Update, change.After)
+		case sources.ChangeRemove:
+			set.Delete = append(set.Delete, change.Before)
+		}
+
+		accepted = append(accepted, change)
+	}
+
+	return set, accepted, nil
+}
@@ -0,0 +1,199 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+const root = "/lustre/projects/team/a/b"
+
+func liveEntries() []*sources.Entry {
+	return []*sources.Entry{
+		{ID: 1, Version: 2, ReportingName: "a", ReportingRoot: root, Directory: root + "/a",
+			Instruction: sources.Backup, Requestor: "user", Faculty: "group"},
+		{ID: 2, ReportingName: "b", ReportingRoot: root, Directory: root + "/b",
+			Instruction: sources.Backup, Requestor: "user", Faculty: "group"},
+		{ID: 3, ReportingName: "c", ReportingRoot: root, Directory: root + "/c",
+			Instruction: sources.NoBackup, Requestor: "user", Faculty: "group"},
+	}
+}
+
+func TestParse(t *testing.T) {
+	rows, err := Parse(strings.NewReader(
+		"directory,reporting_name,id,version,deleted_at\n" +
+			" " + root + "/a , a ,1,2,\n" +
+			root + "/b,b,,,\n" +
+			root + "/c,c,3,,2024-01-02T03:04:05Z\n" +
+			root + "/d,d,x,,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(rows, ShouldResemble, []*Row{
+		{Number: 2, Entry: &sources.Entry{Directory: root + "/a", ReportingName: "a", ID: 1, Version: 2},
+			HasID: true, HasVersion: true},
+		{Number: 3, Entry: &sources.Entry{Directory: root + "/b", ReportingName: "b"}},
+		{Number: 5, Entry: &sources.Entry{Directory: root + "/d", ReportingName: "d"},
+			Errors: []string{`ID "x" is not a number`}},
+	}); !ok {
+		t.Error(err)
+	}
+
+	for _, empty := range []string{"", "directory\n"} {
+		if _, err = Parse(strings.NewReader(empty)); !errors.Is(err, ErrNoRows) {
+			t.Errorf("expected %v for %q, got %v", ErrNoRows, empty, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	live := liveEntries()
+
+	unchanged := *live[0]
+	unchanged.Requestor = "someone_else"
+	renamed := *live[1]
+	renamed.ReportingName = "renamed"
+	invalid := *live[2]
+	invalid.Instruction = "keep"
+	newEntry := *live[0]
+	newEntry.Directory = root + "/new"
+
+	rows := []*Row{
+		{Number: 2, Entry: &unchanged, HasID: true},
+		{Number: 3, Entry: &renamed},
+		{Number: 4, Entry: &invalid, HasID: true},
+		{Number: 5, Entry: &newEntry},
+	}
+
+	diff := Compare(rows, live, false, nil)
+
+	if ok, err := So(diff.Unchanged, ShouldEqual, 1); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(diff.Changes, ShouldHaveLength, 3); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(diff.Changes[0], ShouldResemble, &Change{Kind: sources.ChangeUpdate, Row: 3, Before: live[1],
+		After: &renamed}); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(diff.Changes[1].Errors, ShouldResemble, []string{
+		"Instruction: Input must be backup, nobackup or tempbackup",
+	}); !ok {
+		t.Error(err)
+	}
+
+	newEntry.ID = 0
+	newEntry.Version = 0
+
+	if ok, err := So(diff.Changes[2], ShouldResemble, &Change{Kind: sources.ChangeAdd, Row: 5, After: &newEntry}); !ok {
+		t.Error(err)
+	}
+
+	diff = Compare(rows[:1], live, true, nil)
+
+	if ok, err := So(diff.Changes, ShouldResemble, []*Change{
+		{Kind: sources.ChangeRemove, Before: live[1]},
+		{Kind: sources.ChangeRemove, Before: live[2]},
+	}); !ok {
+		t.Error(err)
+	}
+}
+
+func TestCompareRejectsAmbiguousRows(t *testing.T) {
+	live := liveEntries()
+	live[2].Directory = live[1].Directory
+
+	stale := *live[0]
+	stale.Version = 1
+	stale.ReportingName = "stale"
+
+	missing := *live[0]
+	missing.ID = 99
+
+	ambiguous := *live[1]
+	ambiguous.ReportingName = "ambiguous"
+
+	again := *live[0]
+	again.ReportingName = "again"
+
+	added := *live[0]
+	added.Directory = root + "/new"
+
+	for _, test := range []struct {
+		name  string
+		rows  []*Row
+		error string
+	}{
+		{"stale version", []*Row{{Number: 2, Entry: &stale, HasID: true, HasVersion: true}},
+			"Entry 1 has been changed since this file was exported"},
+		{"unknown ID", []*Row{{Number: 2, Entry: &missing, HasID: true}},
+			"There is no entry with ID 99, leave the ID blank to add one"},
+		{"shared directory", []*Row{{Number: 2, Entry: &ambiguous}},
+			"2 entries have this directory, give the ID of the one to change"},
+		{"changed twice", []*Row{{Number: 2, Entry: &stale, HasID: true}, {Number: 3, Entry: &again}},
+			"Row 2 also changes entry 1"},
+		{"added twice", []*Row{{Number: 2, Entry: &added}, {Number: 3, Entry: &added}},
+			"Row 2 adds an entry for the same directory"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			diff := Compare(test.rows, live, false, nil)
+
+			last := diff.Changes[len(diff.Changes)-1]
+
+			if ok, err := So(last.Errors, ShouldResemble, []string{test.error}); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAccept(t *testing.T) {
+	live := liveEntries()
+
+	renamed := *live[0]
+	renamed.ReportingName = "renamed"
+	invalid := *live[1]
+	invalid.Directory = "/elsewhere"
+	added := *live[0]
+	added.Directory = root + "/new"
+
+	diff := Compare([]*Row{
+		{Number: 2, Entry: &renamed, HasID: true},
+		{Number: 3, Entry: &invalid, HasID: true},
+		{Number: 4, Entry: &added},
+	}, live, true, nil)
+
+	set, accepted, err := diff.Accept([]string{"update-1-2", "add-4", "remove-3-0", "add-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(accepted, ShouldHaveLength, 3); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(set, ShouldResemble, &sources.ChangeSet{
+		Add:    []*sources.Entry{diff.Changes[2].After},
+		Update: []*sources.Entry{diff.Changes[0].After},
+		Delete: []*sources.Entry{live[2]},
+	}); !ok {
+		t.Error(err)
+	}
+
+	if _, _, err = diff.Accept([]string{"update-2-0"}); !errors.Is(err, ErrRejected) {
+		t.Errorf("expected %v, got %v", ErrRejected, err)
+	}
+
+	if _, _, err = diff.Accept([]string{"update-1-1"}); !errors.Is(err, ErrNotInDiff) {
+		t.Errorf("expected %v, got %v", ErrNotInDiff, err)
+	}
+}
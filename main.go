@@ -1,16 +1,23 @@
 package main
 
 import (
+	"backup-plan-ui/resolver"
 	"backup-plan-ui/server"
 	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
+	"context"
 	"embed"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gocarina/gocsv"
 )
 
 //go:embed static
@@ -19,16 +26,85 @@ var staticFiles embed.FS
 //go:embed templates
 var templateFiles embed.FS
 
+const (
+	// defaultDeletedRetention is how long deleted entries can be restored, unless
+	// BACKUP_PLAN_UI_DELETED_RETENTION says otherwise.
+	defaultDeletedRetention = "720h"
+
+	// purgeInterval is how often entries deleted longer ago than that are purged.
+	purgeInterval = time.Hour
+
+	// backendUsage describes the arguments choosing the backend of subcommands
+	// that read the plan from any of them.
+	backendUsage = "<csv <path/to/file.csv> | sqlite <path/to/file.sqlite> | mysql | postgres>"
+)
+
 func main() {
 	log.SetFlags(0) // timestamp comes from systemd
 
-	db := parseArgs(os.Args[1:])
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "migrate" {
+		migrate(args[1:])
+
+		return
+	}
+
+	if len(args) > 0 && args[0] == "resolve" {
+		resolve(args[1:])
+
+		return
+	}
+
+	if len(args) > 0 && args[0] == "snapshot" {
+		snapshot(args[1:])
+
+		return
+	}
+
+	db := parseArgs(args)
+
+	auth, err := authenticatorFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	access, err := accessPolicyFromEnv(auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	policy, err := validationPolicyFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	retention, err := time.ParseDuration(getEnvOrDefault("BACKUP_PLAN_UI_DELETED_RETENTION", defaultDeletedRetention))
+	if err != nil {
+		log.Fatalf("invalid BACKUP_PLAN_UI_DELETED_RETENTION: %s", err)
+	}
 
-	srv, err := server.NewServer(db, templateFiles)
+	srv, err := server.NewServer(db, templateFiles, server.Config{
+		Auth:             auth,
+		Access:           access,
+		DeletedRetention: retention,
+		Listing:          os.Getenv("BACKUP_PLAN_UI_LISTING"),
+		Validation:       policy,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	go srv.PurgeDeletedEntries(context.Background(), purgeInterval)
+
+	if watch := os.Getenv("BACKUP_PLAN_UI_WATCH_INTERVAL"); watch != "" {
+		interval, err := time.ParseDuration(watch)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid BACKUP_PLAN_UI_WATCH_INTERVAL: %q", watch)
+		}
+
+		go srv.WatchForChanges(context.Background(), interval)
+	}
+
 	port := os.Getenv("BACKUP_PLAN_UI_PORT")
 	if port == "" {
 		port = "4000"
@@ -36,17 +112,64 @@ func main() {
 
 	r := chi.NewRouter()
 
-	r.Get("/", srv.ServeHome)
+	if routed, ok := auth.(interface{ Routes(chi.Router) }); ok {
+		routed.Routes(r)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(srv.Authenticate)
+		r.Use(server.ProtectCSRF)
 
-	r.Get("/entries", srv.GetEntries)
-	r.Get("/actions/edit/{id}", srv.AllowUserToEditRow)
-	r.Put("/actions/submit/{id}", srv.SubmitEdits)
-	r.Get("/actions/cancel/{id}", srv.ResetView)
-	r.Get("/actions/delete/{id}", srv.DeleteRow)
-	r.Get("/actions/startDelete/{id}", srv.OpenDeleteDialog)
-	r.Get("/actions/cancelDel", returnEmpty)
-	r.Get("/actions/add", srv.ShowAddRowForm)
-	r.Put("/actions/add", srv.AddNewEntry)
+		r.Get("/", srv.ServeHome)
+
+		r.Get("/entries", srv.GetEntries)
+		r.Get("/events", srv.StreamChanges)
+		r.Get("/export/{format}", srv.ExportEntries)
+		r.Get("/actions/edit/{id}", srv.AllowUserToEditRow)
+		r.Put("/actions/submit/{id}", srv.SubmitEdits)
+		r.Get("/actions/cancel/{id}", srv.ResetView)
+		r.Delete("/actions/delete/{id}", srv.DeleteRow)
+		r.Get("/actions/startDelete/{id}", srv.OpenDeleteDialog)
+		r.Get("/actions/cancelDel", returnEmpty)
+		r.Get("/actions/history/{id}", srv.ShowHistory)
+		r.Get("/actions/deleted", srv.ShowDeleted)
+		r.Post("/actions/restore/{id}", srv.RestoreRow)
+		r.Get("/actions/resolve", srv.ResolvePath)
+		r.Post("/actions/preview", srv.PreviewPatterns)
+		r.Get("/plan-health", srv.ShowPlanHealth)
+		r.Get("/import", srv.ShowImport)
+		r.Post("/import/preview", srv.PreviewImport)
+		r.Post("/import/apply", srv.ApplyImport)
+		r.Get("/proposals", srv.ShowProposals)
+		r.Post("/proposals/{id}/approve", srv.ApproveProposal)
+		r.Post("/proposals/{id}/reject", srv.RejectProposal)
+		r.Get("/snapshots", srv.ShowSnapshots)
+		r.Post("/snapshots/publish", srv.PublishSnapshot)
+		r.Get("/snapshots/diff", srv.ShowSnapshotDiff)
+		r.Get("/actions/closeModal", returnEmpty)
+		r.Get("/actions/add", srv.ShowAddRowForm)
+		r.Put("/actions/add", srv.AddNewEntry)
+
+		r.Route("/api/v1", func(r chi.Router) {
+			r.Get("/entries", srv.APIListEntries)
+			r.Post("/entries", srv.APIAddEntry)
+			r.Get("/entries/deleted", srv.APIListDeleted)
+			r.Post("/entries/{id}/restore", srv.APIRestoreEntry)
+			r.Get("/resolve", srv.APIResolvePath)
+			r.Get("/plan-health", srv.APIPlanHealth)
+			r.Get("/proposals", srv.APIListProposals)
+			r.Post("/proposals/{id}/approve", srv.APIApproveProposal)
+			r.Post("/proposals/{id}/reject", srv.APIRejectProposal)
+			r.Get("/snapshots", srv.APIListSnapshots)
+			r.Post("/snapshots", srv.APIPublishSnapshot)
+			r.Get("/snapshots/diff", srv.APIDiffSnapshots)
+			r.Get("/snapshots/{number}", srv.APIGetSnapshot)
+			r.Get("/entries/{id}", srv.APIGetEntry)
+			r.Put("/entries/{id}", srv.APIReplaceEntry)
+			r.Patch("/entries/{id}", srv.APIPatchEntry)
+			r.Delete("/entries/{id}", srv.APIDeleteEntry)
+		})
+	})
 
 	r.Handle("/static/*", http.FileServerFS(staticFiles))
 
@@ -55,6 +178,75 @@ func main() {
 	}
 }
 
+// authenticatorFromEnv returns the Authenticator selected by the
+// BACKUP_PLAN_UI_AUTH environment variable, or nil to allow anonymous access.
+func authenticatorFromEnv() (server.Authenticator, error) {
+	switch method := os.Getenv("BACKUP_PLAN_UI_AUTH"); method {
+	case "":
+		slog.Warn("No authentication configured: anyone who can reach the server can change the plan")
+
+		return nil, nil
+	case "header":
+		trusted, err := server.ParseTrustedProxies(getEnvOrDefault("BACKUP_PLAN_UI_TRUSTED_PROXIES",
+			server.DefaultTrustedProxies))
+		if err != nil {
+			return nil, err
+		}
+
+		return server.HeaderAuthenticator{
+			UserHeader:     getEnvOrDefault("BACKUP_PLAN_UI_USER_HEADER", server.DefaultUserHeader),
+			GroupsHeader:   getEnvOrDefault("BACKUP_PLAN_UI_GROUPS_HEADER", server.DefaultGroupsHeader),
+			TrustedProxies: trusted,
+		}, nil
+	case "oidc":
+		return server.NewOIDCAuthenticator(context.Background(), server.OIDCConfig{
+			IssuerURL:    os.Getenv("BACKUP_PLAN_UI_OIDC_ISSUER"),
+			ClientID:     os.Getenv("BACKUP_PLAN_UI_OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("BACKUP_PLAN_UI_OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("BACKUP_PLAN_UI_OIDC_REDIRECT_URL"),
+			SessionKey:   []byte(os.Getenv("BACKUP_PLAN_UI_SESSION_KEY")),
+			UserClaim:    os.Getenv("BACKUP_PLAN_UI_OIDC_USER_CLAIM"),
+			GroupsClaim:  os.Getenv("BACKUP_PLAN_UI_OIDC_GROUPS_CLAIM"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown authentication method %q, expected header or oidc", method)
+	}
+}
+
+// accessPolicyFromEnv loads the AccessPolicy in the file named by the
+// BACKUP_PLAN_UI_ACCESS_POLICY environment variable, if it is set.
+func accessPolicyFromEnv(auth server.Authenticator) (*server.AccessPolicy, error) {
+	path := os.Getenv("BACKUP_PLAN_UI_ACCESS_POLICY")
+	if path == "" {
+		return nil, nil
+	}
+
+	if auth == nil {
+		slog.Warn("An access policy without authentication only applies its default grant to everyone")
+	}
+
+	return server.LoadAccessPolicy(path)
+}
+
+// validationPolicyFromEnv loads the validation policy in the file named by the
+// BACKUP_PLAN_UI_VALIDATION_POLICY environment variable, if it is set.
+func validationPolicyFromEnv() (*validation.Policy, error) {
+	path := os.Getenv("BACKUP_PLAN_UI_VALIDATION_POLICY")
+	if path == "" {
+		return nil, nil
+	}
+
+	return validation.LoadPolicy(path)
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
 func parseArgs(args []string) sources.DataSource {
 	if len(args) == 0 {
 		usage("Not enough arguments.")
@@ -79,6 +271,16 @@ func parseArgs(args []string) sources.DataSource {
 			sources.DefaultTableName,
 		)
 		msg = "Using MySQL database"
+	case backend == "postgres":
+		db, err = sources.NewPostgresSource(
+			os.Getenv("PGHOST"),
+			os.Getenv("PGPORT"),
+			os.Getenv("PGUSER"),
+			os.Getenv("PGPASSWORD"),
+			os.Getenv("PGDATABASE"),
+			sources.DefaultTableName,
+		)
+		msg = "Using PostgreSQL database"
 	case len(args) == 1:
 		usage("Not enough arguments.")
 	case backend == "sqlite":
@@ -91,6 +293,12 @@ func parseArgs(args []string) sources.DataSource {
 		usage("Arguments are not recognized.")
 	}
 
+	if migrator, ok := db.(interface{ Migrate() error }); ok && err == nil {
+		// databases created by older versions need their schema brought up to date,
+		// and newer schemas must not be used by this version
+		err = migrator.Migrate()
+	}
+
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -100,6 +308,95 @@ func parseArgs(args []string) sources.DataSource {
 	return db
 }
 
+// migrate brings the schema of the database up to date and reports its
+// version, so it can be done ahead of starting a new version of the server.
+func migrate(args []string) {
+	db := parseArgs(args)
+
+	migrator, ok := db.(interface{ SchemaVersion() (int, error) })
+	if !ok {
+		usage("Only database backends have a schema to migrate.")
+	}
+
+	version, err := migrator.SchemaVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.Info(fmt.Sprintf("Database schema is at version %d", version))
+}
+
+// resolve prints which entry of the plan governs a path, and whether the path is
+// backed up.
+func resolve(args []string) {
+	if len(args) < 2 {
+		usage("Not enough arguments.")
+	}
+
+	db := parseArgs(args[1:])
+
+	entries, err := db.ReadAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	res, err := resolver.Resolve(entries, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%s: %s (%s)\n", res.Path, res.Decision, res.Reason)
+
+	if res.Entry != nil {
+		fmt.Printf("Entry %d: %s %s, requested by %s of %s\n", res.Entry.ID, res.Entry.Instruction,
+			res.Entry.Directory, res.Entry.Requestor, res.Entry.Faculty)
+	}
+
+	if len(res.Match) > 0 {
+		fmt.Println("Match patterns:", strings.Join(res.Match, " "))
+	}
+
+	if len(res.Ignore) > 0 {
+		fmt.Println("Ignore patterns:", strings.Join(res.Ignore, " "))
+	}
+}
+
+// snapshot writes the entries of a published snapshot of the plan, or the latest
+// one, to stdout as CSV, for the backup tooling to read.
+func snapshot(args []string) {
+	if len(args) < 2 {
+		usage("Not enough arguments.")
+	}
+
+	db := parseArgs(args[1:])
+
+	var (
+		snap *sources.Snapshot
+		err  error
+	)
+
+	if args[0] == "latest" {
+		snap, err = sources.LatestSnapshot(db)
+	} else {
+		var number uint64
+
+		if number, err = strconv.ParseUint(args[0], 10, 64); err == nil {
+			snap, err = db.GetSnapshot(number)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.Info(fmt.Sprintf("Snapshot %d published by %s at %s", snap.Number, snap.PublishedBy,
+		snap.PublishedAt.Format(time.RFC3339)))
+
+	if err = gocsv.Marshal(&snap.Entries, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func usage(msg string) {
 	if msg != "" {
 		slog.Error(msg)
@@ -108,6 +405,10 @@ func usage(msg string) {
 	fmt.Println("  backup-plan-ui csv <path/to/file.csv>")
 	fmt.Println("  backup-plan-ui sqlite <path/to/file.sqlite>")
 	fmt.Println("  backup-plan-ui mysql")
+	fmt.Println("  backup-plan-ui postgres")
+	fmt.Println("  backup-plan-ui migrate <sqlite <path/to/file.sqlite> | mysql | postgres>")
+	fmt.Println("  backup-plan-ui resolve </path/to/look/up> " + backendUsage)
+	fmt.Println("  backup-plan-ui snapshot <latest | number> " + backendUsage)
 	os.Exit(2)
 }
 
@@ -0,0 +1,203 @@
+package resolver
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SplitPatterns returns the glob patterns in the space separated Match or Ignore
+// field of an entry.
+func SplitPatterns(s string) []string {
+	return strings.Fields(s)
+}
+
+// CheckPatterns returns an error for each of the patterns that is not valid glob
+// syntax, as understood by path.Match.
+func CheckPatterns(patterns []string) []error {
+	var errs []error
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", pattern, err))
+		}
+	}
+
+	return errs
+}
+
+// Unreachable returns, for each match pattern that only matches paths that one
+// of the ignore patterns also matches, the first such ignore pattern. Such match
+// patterns never cause anything to be backed up. Invalid patterns are skipped.
+func Unreachable(match, ignore []string) map[string]string {
+	unreachable := make(map[string]string)
+
+	for _, m := range match {
+		for _, i := range ignore {
+			if covers(i, m) {
+				unreachable[m] = i
+
+				break
+			}
+		}
+	}
+
+	return unreachable
+}
+
+// covers returns true if every path the pattern inner matches is also matched by
+// outer, taking into account that patterns without a slash are matched against
+// file names and patterns with one against the path relative to the directory.
+func covers(outer, inner string) bool {
+	outerIsPath, innerIsPath := strings.Contains(outer, "/"), strings.Contains(inner, "/")
+
+	switch {
+	case outerIsPath && !innerIsPath:
+		// inner matches files at any depth, outer only at some
+		return false
+	case !outerIsPath && innerIsPath:
+		inner = inner[strings.LastIndex(inner, "/")+1:]
+	}
+
+	o, err := tokenise(outer)
+	if err != nil {
+		return false
+	}
+
+	i, err := tokenise(inner)
+	if err != nil {
+		return false
+	}
+
+	return coversTokens(o, i)
+}
+
+// globToken is a single literal character, "?", "*" or character class of a
+// glob pattern.
+type globToken struct {
+	kind    byte
+	literal rune
+	class   string
+}
+
+const (
+	tokenLiteral = 'l'
+	tokenAny     = '?'
+	tokenStar    = '*'
+	tokenClass   = '['
+)
+
+func tokenise(pattern string) ([]globToken, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	var tokens []globToken
+
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			tokens = append(tokens, globToken{kind: tokenStar})
+		case '?':
+			tokens = append(tokens, globToken{kind: tokenAny})
+		case '[':
+			end := classEnd(runes, i)
+			tokens = append(tokens, globToken{kind: tokenClass, class: string(runes[i : end+1])})
+			i = end
+		case '\\':
+			i++
+
+			fallthrough
+		default:
+			tokens = append(tokens, globToken{kind: tokenLiteral, literal: runes[i]})
+		}
+	}
+
+	return tokens, nil
+}
+
+// classEnd returns the index of the "]" closing the character class starting at
+// runes[start], in a pattern already known to be valid, where classes cannot be
+// empty or contain an unescaped "]".
+func classEnd(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+
+	return len(runes) - 1
+}
+
+// coversTokens returns true if every string matched by inner is matched by outer.
+// The result for each pair of remaining tokens is remembered, so patterns with
+// many "*" take time proportional to the product of their lengths.
+func coversTokens(outer, inner []globToken) bool {
+	const (
+		unknown = iota
+		covered
+		uncovered
+	)
+
+	width := len(inner) + 1
+	results := make([]uint8, (len(outer)+1)*width)
+
+	var covers func(i, j int) bool
+
+	covers = func(i, j int) bool {
+		result := &results[i*width+j]
+		if *result != unknown {
+			return *result == covered
+		}
+
+		var ok bool
+
+		switch {
+		case i == len(outer):
+			ok = j == len(inner)
+		case outer[i].kind == tokenStar:
+			ok = covers(i+1, j) || (j < len(inner) && !isSlash(inner[j]) && covers(i, j+1))
+		default:
+			ok = j < len(inner) && coversToken(outer[i], inner[j]) && covers(i+1, j+1)
+		}
+
+		*result = uncovered
+		if ok {
+			*result = covered
+		}
+
+		return ok
+	}
+
+	return covers(0, 0)
+}
+
+// coversToken returns true if every character matched by inner is matched by
+// outer, which is not a "*".
+func coversToken(outer, inner globToken) bool {
+	switch {
+	case inner.kind == tokenStar:
+		return false
+	case outer.kind == tokenAny:
+		return !isSlash(inner)
+	case inner.kind == tokenLiteral:
+		if outer.kind == tokenLiteral {
+			return outer.literal == inner.literal
+		}
+
+		ok, _ := path.Match(outer.class, string(inner.literal))
+
+		return ok
+	default:
+		return outer == inner
+	}
+}
+
+func isSlash(t globToken) bool {
+	return t.kind == tokenLiteral && t.literal == '/'
+}
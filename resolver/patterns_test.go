@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestCheckPatterns(t *testing.T) {
+	errs := CheckPatterns([]string{"*.cram", "[abc", "sub/?.txt", "x\\", "[a-z]*.bam", "[]"})
+
+	if ok, err := So(errs, ShouldHaveLength, 3); !ok {
+		t.Fatal(err)
+	}
+
+	for i, bad := range []string{`"[abc"`, `"x\\"`, `"[]"`} {
+		if ok, err := So(errs[i].Error(), ShouldStartWith, bad); !ok {
+			t.Error(err)
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	testCases := []struct {
+		name        string
+		match       string
+		ignore      string
+		unreachable bool
+	}{
+		{"The same pattern", "*.cram", "*.cram", true},
+		{"A wider star", "*.cram", "*", true},
+		{"A star covers a literal prefix", "sample_*.cram", "*.cram", true},
+		{"A literal does not cover a star", "*.cram", "sample_*.cram", false},
+		{"Question marks cover single characters", "a[0-9].txt", "a?.txt", true},
+		{"Question marks do not cover stars", "a*.txt", "a?.txt", false},
+		{"Classes cover the literals in them", "b.log", "[abc].log", true},
+		{"Classes do not cover other literals", "d.log", "[abc].log", false},
+		{"Different extensions", "*.cram", "*.bam", false},
+		{"Name patterns cover the names at the end of paths", "sub/*.tmp", "*.tmp", true},
+		{"Path patterns do not cover names at any depth", "*.tmp", "sub/*.tmp", false},
+		{"Stars do not cover slashes", "a/b/c.txt", "a/*.txt", false},
+		{"Path patterns cover paths", "a/b/c.txt", "a/*/c.txt", true},
+		{"Escaped characters are literals", `a\*`, "a?", true},
+		{"Invalid patterns cover nothing", "*.cram", "[", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			unreachable := Unreachable(SplitPatterns(tt.match), SplitPatterns(tt.ignore))
+
+			if ok, err := So(len(unreachable) == 1, ShouldEqual, tt.unreachable); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUnreachableWithManyStars(t *testing.T) {
+	ignore := strings.Repeat("*a", 20) + "*b"
+	match := strings.Repeat("a", 200) + ".cram"
+
+	start := time.Now()
+	unreachable := Unreachable([]string{match}, []string{ignore})
+
+	if ok, err := So(time.Since(start), ShouldBeLessThan, 100*time.Millisecond); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(unreachable, ShouldBeEmpty); !ok {
+		t.Error(err)
+	}
+}
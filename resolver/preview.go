@@ -0,0 +1,66 @@
+package resolver
+
+import (
+	"path"
+
+	"backup-plan-ui/sources"
+)
+
+// Example is a path of a Preview, with the reason it was included or excluded.
+type Example struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Preview counts which paths of a file listing an entry, on its own, would back
+// up, keeping the first few of each as examples.
+type Preview struct {
+	Entry            *sources.Entry `json:"entry"`
+	Included         int            `json:"included"`
+	Excluded         int            `json:"excluded"`
+	Outside          int            `json:"outside"`
+	IncludedExamples []Example      `json:"included_examples"`
+	ExcludedExamples []Example      `json:"excluded_examples"`
+
+	maxExamples int
+}
+
+// NewPreview returns an empty Preview of the given entry, which keeps up to
+// maxExamples examples of included and of excluded paths.
+func NewPreview(entry *sources.Entry, maxExamples int) *Preview {
+	return &Preview{Entry: entry, maxExamples: maxExamples}
+}
+
+// Add decides what the entry does with the listed path name, which is relative
+// to the entry's Directory unless it is absolute. Paths outside the Directory
+// are only counted, as are all paths when the Directory is not absolute.
+func (p *Preview) Add(name string) {
+	if !path.IsAbs(name) {
+		name = path.Join(p.Entry.Directory, name)
+	}
+
+	res, err := Resolve([]*sources.Entry{p.Entry}, name)
+	if err != nil || res.Entry == nil {
+		p.Outside++
+
+		return
+	}
+
+	example := Example{Path: res.Path, Reason: res.Reason}
+
+	if res.Decision == NoBackup {
+		p.Excluded++
+		p.ExcludedExamples = p.addExample(p.ExcludedExamples, example)
+	} else {
+		p.Included++
+		p.IncludedExamples = p.addExample(p.IncludedExamples, example)
+	}
+}
+
+func (p *Preview) addExample(examples []Example, example Example) []Example {
+	if len(examples) < p.maxExamples {
+		examples = append(examples, example)
+	}
+
+	return examples
+}
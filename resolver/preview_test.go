@@ -0,0 +1,53 @@
+package resolver
+
+import (
+	"testing"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestPreview(t *testing.T) {
+	entry := &sources.Entry{ID: 1, Directory: "/lustre/projects/a", Instruction: sources.Backup,
+		Match: "*.cram", Ignore: "tmp_*"}
+
+	preview := NewPreview(entry, 1)
+
+	for _, p := range []string{
+		"/lustre/projects/a/x.cram",
+		"sub/y.cram",
+		"/lustre/projects/a/tmp_x.cram",
+		"/lustre/projects/a/x.bam",
+		"/lustre/projects/b/x.cram",
+	} {
+		preview.Add(p)
+	}
+
+	if ok, err := So(preview.Included, ShouldEqual, 2); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(preview.Excluded, ShouldEqual, 2); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(preview.Outside, ShouldEqual, 1); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(preview.IncludedExamples, ShouldResemble, []Example{{
+		Path:   "/lustre/projects/a/x.cram",
+		Reason: "/lustre/projects/a is backed up, and this path matches the pattern *.cram",
+	}}); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(preview.ExcludedExamples, ShouldHaveLength, 1); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(preview.ExcludedExamples[0].Path, ShouldEqual, "/lustre/projects/a/tmp_x.cram"); !ok {
+		t.Error(err)
+	}
+}
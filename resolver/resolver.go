@@ -0,0 +1,179 @@
+// Package resolver works out which entry of the plan governs a file path, and
+// whether the plan has the file backed up.
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"backup-plan-ui/sources"
+)
+
+// Decision is what happens to a file according to the plan.
+type Decision string
+
+const (
+	// Backup means the file is backed up.
+	Backup Decision = "backup"
+
+	// TempBackup means the file is backed up, and the backup is kept for 3 months.
+	TempBackup Decision = "tempbackup"
+
+	// NoBackup means the file is not backed up, because of its entry's
+	// instruction or its match and ignore patterns.
+	NoBackup Decision = "nobackup"
+
+	// Unplanned means no entry covers the file.
+	Unplanned Decision = "unplanned"
+)
+
+// Resolution explains the Decision for a path. Entry is the entry with the
+// deepest Directory containing the path, or nil if there is none. Match and
+// Ignore are the patterns of the entry that were applied to the path, and
+// MatchedBy and IgnoredBy the first of them that the path matched.
+type Resolution struct {
+	Path      string         `json:"path"`
+	Entry     *sources.Entry `json:"entry"`
+	Match     []string       `json:"match,omitempty"`
+	Ignore    []string       `json:"ignore,omitempty"`
+	MatchedBy string         `json:"matched_by,omitempty"`
+	IgnoredBy string         `json:"ignored_by,omitempty"`
+	Decision  Decision       `json:"decision"`
+	Reason    string         `json:"reason"`
+}
+
+var ErrRelativePath = errors.New("path must be absolute")
+
+// Resolve returns what the plan made of the given entries does with the file at
+// the absolute path p.
+//
+// The entry whose Directory is the deepest one containing p governs it; of
+// entries with the same Directory, the one with the lowest ID wins. The Match
+// and Ignore patterns of backup entries are space separated globs, matched
+// against the path relative to the Directory if they contain a slash and
+// against the file name otherwise. Ignore patterns win over Match patterns, and
+// when there are Match patterns only the paths matching one are backed up.
+func Resolve(entries []*sources.Entry, p string) (*Resolution, error) {
+	if !path.IsAbs(p) {
+		return nil, fmt.Errorf("%w: %q", ErrRelativePath, p)
+	}
+
+	res := &Resolution{Path: path.Clean(p)}
+
+	res.Entry = governingEntry(entries, res.Path)
+	if res.Entry == nil {
+		res.Decision = Unplanned
+		res.Reason = "no entry covers this path"
+
+		return res, nil
+	}
+
+	dir := path.Clean(res.Entry.Directory)
+
+	switch res.Entry.Instruction {
+	case sources.Backup:
+		res.applyPatterns(dir)
+	case sources.TempBackup:
+		res.Decision = TempBackup
+		res.Reason = fmt.Sprintf("%s is temporarily backed up", dir)
+	default:
+		res.Decision = NoBackup
+		res.Reason = fmt.Sprintf("%s is not backed up", dir)
+	}
+
+	return res, nil
+}
+
+// governingEntry returns the entry with the deepest directory containing p.
+func governingEntry(entries []*sources.Entry, p string) *sources.Entry {
+	var (
+		best      *sources.Entry
+		bestDepth = -1
+	)
+
+	for _, entry := range entries {
+		dir := path.Clean(entry.Directory)
+		if !contains(dir, p) {
+			continue
+		}
+
+		depth := len(dir)
+		if depth > bestDepth || (depth == bestDepth && entry.ID < best.ID) {
+			best, bestDepth = entry, depth
+		}
+	}
+
+	return best
+}
+
+// Parent returns the entry governing the directory of the given entry, ignoring
+// entries for the same directory, or nil if it is not nested in another entry.
+func Parent(entries []*sources.Entry, entry *sources.Entry) *sources.Entry {
+	dir := path.Clean(entry.Directory)
+
+	var others []*sources.Entry
+
+	for _, e := range entries {
+		if path.Clean(e.Directory) != dir {
+			others = append(others, e)
+		}
+	}
+
+	return governingEntry(others, dir)
+}
+
+// contains returns true if p is dir or inside it. Both must be clean.
+func contains(dir, p string) bool {
+	if !path.IsAbs(dir) {
+		return false
+	}
+
+	if dir == "/" || dir == p {
+		return true
+	}
+
+	return strings.HasPrefix(p, dir+"/")
+}
+
+func (res *Resolution) applyPatterns(dir string) {
+	rel := strings.TrimPrefix(strings.TrimPrefix(res.Path, dir), "/")
+
+	res.Match = SplitPatterns(res.Entry.Match)
+	res.Ignore = SplitPatterns(res.Entry.Ignore)
+	res.MatchedBy = firstMatch(res.Match, rel)
+	res.IgnoredBy = firstMatch(res.Ignore, rel)
+
+	switch {
+	case res.IgnoredBy != "":
+		res.Decision = NoBackup
+		res.Reason = fmt.Sprintf("%s is backed up, but this path matches the ignore pattern %s", dir, res.IgnoredBy)
+	case len(res.Match) > 0 && res.MatchedBy == "":
+		res.Decision = NoBackup
+		res.Reason = fmt.Sprintf("%s is backed up, but this path matches none of its match patterns", dir)
+	case res.MatchedBy != "":
+		res.Decision = Backup
+		res.Reason = fmt.Sprintf("%s is backed up, and this path matches the pattern %s", dir, res.MatchedBy)
+	default:
+		res.Decision = Backup
+		res.Reason = fmt.Sprintf("%s is backed up", dir)
+	}
+}
+
+// firstMatch returns the first of the patterns matching the path rel, relative
+// to the directory of the entry, or "" if none do.
+func firstMatch(patterns []string, rel string) string {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return pattern
+		}
+	}
+
+	return ""
+}
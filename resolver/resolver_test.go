@@ -0,0 +1,84 @@
+package resolver
+
+import (
+	"errors"
+	"testing"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestResolve(t *testing.T) {
+	project := &sources.Entry{ID: 1, Directory: "/lustre/projects/a", Instruction: sources.Backup}
+	output := &sources.Entry{ID: 2, Directory: "/lustre/projects/a/output/", Instruction: sources.NoBackup}
+	crams := &sources.Entry{ID: 3, Directory: "/lustre/projects/a/output/crams", Instruction: sources.Backup,
+		Match: "*.cram sub/*.crai", Ignore: "tmp_*"}
+	scratch := &sources.Entry{ID: 4, Directory: "/lustre/scratch", Instruction: sources.TempBackup}
+	duplicate := &sources.Entry{ID: 5, Directory: "/lustre/scratch", Instruction: sources.NoBackup}
+
+	entries := []*sources.Entry{duplicate, crams, output, project, scratch}
+
+	testCases := []struct {
+		name      string
+		path      string
+		entry     *sources.Entry
+		decision  Decision
+		matchedBy string
+		ignoredBy string
+	}{
+		{"Paths outside every directory are unplanned", "/home/user/file", nil, Unplanned, "", ""},
+		{"Directories with a common prefix do not contain each other", "/lustre/projects/ab/file", nil,
+			Unplanned, "", ""},
+		{"The directory itself is governed by its entry", "/lustre/projects/a", project, Backup, "", ""},
+		{"Files are governed by their directory", "/lustre/projects/a/file.txt", project, Backup, "", ""},
+		{"The deepest directory wins", "/lustre/projects/a/output/file.txt", output, NoBackup, "", ""},
+		{"Paths are cleaned", "/lustre/projects/a/output/../file.txt", project, Backup, "", ""},
+		{"Matching files are backed up", "/lustre/projects/a/output/crams/x.cram", crams, Backup, "*.cram", ""},
+		{"Patterns without a slash match file names", "/lustre/projects/a/output/crams/sub/y.cram", crams,
+			Backup, "*.cram", ""},
+		{"Patterns with a slash match relative paths", "/lustre/projects/a/output/crams/sub/y.crai", crams,
+			Backup, "sub/*.crai", ""},
+		{"Files not matching are not backed up", "/lustre/projects/a/output/crams/x.bam", crams,
+			NoBackup, "", ""},
+		{"Ignore wins over match", "/lustre/projects/a/output/crams/tmp_x.cram", crams, NoBackup,
+			"*.cram", "tmp_*"},
+		{"Temporary backups", "/lustre/scratch/file", scratch, TempBackup, "", ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Resolve(entries, tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(res.Entry, ShouldEqual, tt.entry); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(res.Decision, ShouldEqual, tt.decision); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(res.MatchedBy, ShouldEqual, tt.matchedBy); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(res.IgnoredBy, ShouldEqual, tt.ignoredBy); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(res.Reason, ShouldNotBeBlank); !ok {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("Relative paths are rejected", func(t *testing.T) {
+		_, err := Resolve(entries, "lustre/projects/a")
+		if !errors.Is(err, ErrRelativePath) {
+			t.Errorf("expected %v, got %v", ErrRelativePath, err)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Role decides which entries a user may change.
+type Role string
+
+const (
+	// RoleViewer may not change any entry.
+	RoleViewer Role = "viewer"
+
+	// RoleEditor may change entries of the faculties they are granted.
+	RoleEditor Role = "editor"
+
+	// RoleAdmin may change every entry.
+	RoleAdmin Role = "admin"
+)
+
+// Grant gives a Role to a user or a group. Faculties are only used by editors,
+// and are matched ignoring case.
+type Grant struct {
+	Role      Role     `yaml:"role"`
+	Faculties []string `yaml:"faculties"`
+}
+
+// AccessPolicy maps users and groups to the entries they may change. Users get
+// every grant given to them or any of their groups, plus the Default grant.
+// With RequireApproval, changes by anyone but admins are proposed for an admin
+// to approve instead of being made straight away.
+type AccessPolicy struct {
+	Default         Grant            `yaml:"default"`
+	Users           map[string]Grant `yaml:"users"`
+	Groups          map[string]Grant `yaml:"groups"`
+	RequireApproval bool             `yaml:"require_approval"`
+}
+
+var (
+	ErrForbidden   = errors.New("you are not allowed to change entries of this faculty")
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+// LoadAccessPolicy reads an AccessPolicy from a YAML file, such as:
+//
+//	default:
+//	  role: viewer
+//	users:
+//	  alice:
+//	    role: admin
+//	groups:
+//	  hgi:
+//	    role: editor
+//	    faculties: [hgi]
+//	require_approval: true
+func LoadAccessPolicy(path string) (*AccessPolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var policy AccessPolicy
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err = dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid access policy %s: %w", path, err)
+	}
+
+	if err = policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid access policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+func (p *AccessPolicy) validate() error {
+	if p.Default.Role == "" {
+		p.Default.Role = RoleViewer
+	}
+
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+
+	for name, grant := range p.Users {
+		if err := grant.validate(); err != nil {
+			return fmt.Errorf("user %s: %w", name, err)
+		}
+	}
+
+	for name, grant := range p.Groups {
+		if err := grant.validate(); err != nil {
+			return fmt.Errorf("group %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (g Grant) validate() error {
+	switch g.Role {
+	case RoleViewer, RoleEditor, RoleAdmin:
+		return nil
+	default:
+		return fmt.Errorf("%w %q, expected %s, %s or %s", ErrInvalidRole, g.Role, RoleViewer, RoleEditor, RoleAdmin)
+	}
+}
+
+// permissions are what a user may change under an AccessPolicy.
+type permissions struct {
+	admin     bool
+	faculties map[string]bool
+}
+
+// permissionsFor combines the grants given to the user, who is nil for
+// anonymous requests.
+func (p *AccessPolicy) permissionsFor(user *User) permissions {
+	perms := permissions{faculties: make(map[string]bool)}
+	perms.add(p.Default)
+
+	if user == nil {
+		return perms
+	}
+
+	if grant, ok := p.Users[user.Name]; ok {
+		perms.add(grant)
+	}
+
+	for _, group := range user.Groups {
+		if grant, ok := p.Groups[group]; ok {
+			perms.add(grant)
+		}
+	}
+
+	return perms
+}
+
+func (perms *permissions) add(grant Grant) {
+	switch grant.Role {
+	case RoleAdmin:
+		perms.admin = true
+	case RoleEditor:
+		for _, faculty := range grant.Faculties {
+			perms.faculties[strings.ToLower(faculty)] = true
+		}
+	}
+}
+
+func (perms permissions) canManage(faculty string) bool {
+	return perms.admin || perms.faculties[strings.ToLower(faculty)]
+}
+
+func (perms permissions) canManageAny() bool {
+	return perms.admin || len(perms.faculties) > 0
+}
+
+// canManage returns true if the user making the request may change entries of
+// all the given faculties. Without an AccessPolicy anyone may change anything.
+func (s Server) canManage(r *http.Request, faculties ...string) bool {
+	if s.access == nil {
+		return true
+	}
+
+	perms := s.access.permissionsFor(getUser(r))
+
+	for _, faculty := range faculties {
+		if !perms.canManage(faculty) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// canManageAny returns true if the user making the request may change entries
+// of at least one faculty.
+func (s Server) canManageAny(r *http.Request) bool {
+	return s.access == nil || s.access.permissionsFor(getUser(r)).canManageAny()
+}
+
+// isAdmin returns true if the user making the request is an admin under the
+// AccessPolicy, and so may review proposed changes.
+func (s Server) isAdmin(r *http.Request) bool {
+	return s.access != nil && s.access.permissionsFor(getUser(r)).admin
+}
+
+// needsApproval returns true if the changes the user making the request may
+// make must be approved by an admin first.
+func (s Server) needsApproval(r *http.Request) bool {
+	return s.access != nil && s.access.RequireApproval && !s.isAdmin(r)
+}
+
+// canPublish returns true if the user making the request may publish snapshots
+// of the plan: admins under the AccessPolicy, or anyone without one.
+func (s Server) canPublish(r *http.Request) bool {
+	return s.access == nil || s.isAdmin(r)
+}
+
+// authorise returns ErrForbidden unless the user making the request may change
+// entries of all the given faculties.
+func (s Server) authorise(r *http.Request, faculties ...string) error {
+	if !s.canManage(r, faculties...) {
+		return fmt.Errorf("%w: %s", ErrForbidden, strings.Join(faculties, ", "))
+	}
+
+	return nil
+}
+
+// statusOrForbidden returns http.StatusForbidden for ErrForbidden, and status
+// otherwise.
+func statusOrForbidden(err error, status int) int {
+	if errors.Is(err, ErrForbidden) {
+		return http.StatusForbidden
+	}
+
+	return status
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func writeTestPolicy(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "access.yaml")
+
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadAccessPolicy(t *testing.T) {
+	t.Run("Policies are read from YAML", func(t *testing.T) {
+		policy, err := LoadAccessPolicy(writeTestPolicy(t, `
+users:
+  alice:
+    role: admin
+groups:
+  hgi:
+    role: editor
+    faculties: [HGI, group]
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := &AccessPolicy{
+			Default: Grant{Role: RoleViewer},
+			Users:   map[string]Grant{"alice": {Role: RoleAdmin}},
+			Groups:  map[string]Grant{"hgi": {Role: RoleEditor, Faculties: []string{"HGI", "group"}}},
+		}
+
+		if ok, err := So(policy, ShouldResemble, expected); !ok {
+			t.Error(err)
+		}
+	})
+
+	for name, content := range map[string]string{
+		"Unknown roles are rejected":  "users:\n  alice:\n    role: owner\n",
+		"Unknown fields are rejected": "users:\n  alice:\n    rol: admin\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadAccessPolicy(writeTestPolicy(t, content))
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	t.Run("Unknown roles are reported", func(t *testing.T) {
+		_, err := LoadAccessPolicy(writeTestPolicy(t, "default:\n  role: owner\n"))
+		if !errors.Is(err, ErrInvalidRole) {
+			t.Errorf("expected ErrInvalidRole, got %v", err)
+		}
+	})
+}
+
+func TestPermissionsFor(t *testing.T) {
+	policy := &AccessPolicy{
+		Default: Grant{Role: RoleEditor, Faculties: []string{"everyone"}},
+		Users:   map[string]Grant{"alice": {Role: RoleAdmin}},
+		Groups:  map[string]Grant{"hgi": {Role: RoleEditor, Faculties: []string{"HGI"}}},
+	}
+
+	tests := []struct {
+		name      string
+		user      *User
+		faculty   string
+		canManage bool
+	}{
+		{"Admins can change any faculty", &User{Name: "alice"}, "ssg", true},
+		{"Editors can change their group's faculties", &User{Name: "bob", Groups: []string{"hgi"}}, "hgi", true},
+		{"Editors cannot change other faculties", &User{Name: "bob", Groups: []string{"hgi"}}, "ssg", false},
+		{"Everyone gets the default grant", &User{Name: "carol"}, "everyone", true},
+		{"Anonymous users get the default grant", nil, "everyone", true},
+		{"Anonymous users get nothing more", nil, "hgi", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perms := policy.permissionsFor(tt.user)
+
+			if ok, err := So(perms.canManage(tt.faculty), ShouldEqual, tt.canManage); !ok {
+				t.Error(err)
+			}
+		})
+	}
+
+	if ok, err := So((&AccessPolicy{}).permissionsFor(nil).canManageAny(), ShouldBeFalse); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.access = &AccessPolicy{
+		Default: Grant{Role: RoleViewer},
+		Groups:  map[string]Grant{"editors": {Role: RoleEditor, Faculties: []string{originalEntries[0].Faculty}}},
+	}
+
+	viewer := &User{Name: "victor"}
+	editor := &User{Name: "eve", Groups: []string{"editors"}}
+
+	t.Run("Viewers do not see edit buttons", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.GetEntries(w, withUser(httptest.NewRequest(http.MethodGet, "/entries", nil), viewer))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldNotContainSubstring, "edit row"); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.GetEntries(w, withUser(httptest.NewRequest(http.MethodGet, "/entries", nil), editor))
+
+		body = getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "edit row"); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Viewers cannot open the edit form", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.AllowUserToEditRow(w, withUser(makeRequest(originalEntries[0].ID), viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Viewers cannot edit entries", func(t *testing.T) {
+		entry := *originalEntries[0]
+		entry.ReportingName = "changed"
+
+		r := makeFormRequest(createFormFromEntry(entry), "/actions/submit", fmt.Sprint(entry.ID))
+
+		w := httptest.NewRecorder()
+		s.SubmitEdits(w, withUser(r, viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+
+		unchanged, err := s.db.GetEntry(entry.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(unchanged, ShouldResemble, originalEntries[0]); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Editors cannot move entries to another faculty", func(t *testing.T) {
+		entry := *originalEntries[0]
+		entry.Faculty = "other"
+
+		r := makeFormRequest(createFormFromEntry(entry), "/actions/submit", fmt.Sprint(entry.ID))
+
+		w := httptest.NewRecorder()
+		s.SubmitEdits(w, withUser(r, editor))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Viewers cannot add or delete entries", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := makeFormRequest(createFormFromEntry(*originalEntries[0]), "/actions/add", "")
+		s.AddNewEntry(w, withUser(r, viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.DeleteRow(w, withUser(makeVersionedRequest(originalEntries[1].ID, originalEntries[1].Version), viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(len(entries), ShouldEqual, sources.NumTestDataRows); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("The API refuses forbidden changes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := makeJSONRequest(http.MethodDelete, fmt.Sprint(originalEntries[1].ID), "")
+		s.APIDeleteEntry(w, withUser(r, viewer))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusForbidden, &apiErr)
+	})
+
+	t.Run("Editors can delete entries of their faculty", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.DeleteRow(w, withUser(makeVersionedRequest(originalEntries[1].ID, originalEntries[1].Version), editor))
+
+		_ = getBodyAndCheckStatusOK(t, w)
+
+		if _, err := s.db.GetEntry(originalEntries[1].ID); !errors.Is(err, sources.ErrNoEntry) {
+			t.Errorf("expected ErrNoEntry, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	contentTypeJSON  = "application/json"
+	headerTotalCount = "X-Total-Count"
+)
+
+type apiError struct {
+	Error   string            `json:"error"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Current *sources.Entry    `json:"current,omitempty"`
+}
+
+var (
+	errInvalidBody       = errors.New("request body is not a valid entry")
+	errValidationFailure = errors.New("entry failed validation")
+	errInvalidQuery      = errors.New("invalid query")
+)
+
+func (s Server) writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+func (s Server) writeJSONError(w http.ResponseWriter, err error, statusCode int) {
+	if statusCode >= http.StatusInternalServerError {
+		slog.Error(err.Error())
+	}
+
+	s.writeJSON(w, statusCode, apiError{Error: err.Error()})
+}
+
+func (s Server) writeValidationErrors(w http.ResponseWriter, errs map[formField]string) {
+	s.writeJSON(w, http.StatusUnprocessableEntity, apiError{
+		Error:  errValidationFailure.Error(),
+		Fields: convertErrors(errs),
+	})
+}
+
+// statusForError maps errors returned by a DataSource to the HTTP status the API
+// responds with.
+func statusForError(err error) int {
+	if errors.Is(err, sources.ErrNoEntry) || errors.Is(err, sources.ErrNoProposal) ||
+		errors.Is(err, sources.ErrNoSnapshot) {
+		return http.StatusNotFound
+	}
+
+	if errors.Is(err, sources.ErrVersionConflict) || errors.Is(err, sources.ErrNotDeleted) ||
+		errors.Is(err, sources.ErrNotPending) {
+		return http.StatusConflict
+	}
+
+	if errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotReviewer) || errors.Is(err, ErrNotPublisher) {
+		return http.StatusForbidden
+	}
+
+	if isQueryError(err) || errors.Is(err, errInvalidSnapshot) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeChangeError responds with the error returned when changing the entry with
+// the given ID. Conflicts include the entry as it is currently stored, so the
+// client can decide how to proceed.
+func (s Server) writeChangeError(w http.ResponseWriter, id uint64, err error) {
+	if !errors.Is(err, sources.ErrVersionConflict) {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	current, getErr := s.db.GetEntry(id)
+	if getErr != nil {
+		s.writeJSONError(w, getErr, statusForError(getErr))
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusConflict, apiError{Error: err.Error(), Current: current})
+}
+
+func getIDFromURL(r *http.Request) (uint64, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid entry ID: %w", err)
+	}
+
+	return id, nil
+}
+
+func decodeEntry(r *http.Request, entry *sources.Entry) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(entry); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidBody, err)
+	}
+
+	return nil
+}
+
+// APIListEntries responds with the entries in the plan as a JSON array. They can
+// be filtered, sorted and paged with the same query parameters as GetEntries, and
+// the total number of entries matching the filter is given in a header.
+func (s Server) APIListEntries(w http.ResponseWriter, r *http.Request) {
+	query, err := queryFromRequest(r, 0)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	page, err := s.db.Query(query)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if page.Entries == nil {
+		page.Entries = []*sources.Entry{}
+	}
+
+	w.Header().Set(headerTotalCount, strconv.Itoa(page.Total))
+	s.writeJSON(w, http.StatusOK, page.Entries)
+}
+
+// APIGetEntry responds with the entry whose ID is given in the URL.
+func (s Server) APIGetEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	entry, err := s.db.GetEntry(id)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, entry)
+}
+
+// APIAddEntry validates the entry in the request body and adds it to the plan.
+// Any ID in the body is ignored; the stored entry is returned with its new ID.
+func (s Server) APIAddEntry(w http.ResponseWriter, r *http.Request) {
+	var newEntry sources.Entry
+
+	if err := decodeEntry(r, &newEntry); err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	newEntry.ID = 0
+	setRequestor(r, &newEntry)
+
+	if validationErrors := s.validateEntry(&newEntry); len(validationErrors) > 0 {
+		s.writeValidationErrors(w, validationErrors)
+
+		return
+	}
+
+	proposal, err := s.addEntry(r, &newEntry)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if proposal != nil {
+		s.writeJSON(w, http.StatusAccepted, proposal)
+
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, newEntry.ID))
+	s.writeJSON(w, http.StatusCreated, newEntry)
+}
+
+// APIReplaceEntry replaces every field of an existing entry with the entry in
+// the request body. The body must contain the Version of the entry it replaces.
+func (s Server) APIReplaceEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	var updatedEntry sources.Entry
+
+	if err = decodeEntry(r, &updatedEntry); err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	s.updateEntryFromAPI(w, r, id, &updatedEntry)
+}
+
+func (s Server) updateEntryFromAPI(w http.ResponseWriter, r *http.Request, id uint64, updatedEntry *sources.Entry) {
+	updatedEntry.ID = id
+
+	if validationErrors := s.validateEntry(updatedEntry); len(validationErrors) > 0 {
+		s.writeValidationErrors(w, validationErrors)
+
+		return
+	}
+
+	proposal, err := s.updateEntry(r, updatedEntry)
+	if err != nil {
+		s.writeChangeError(w, id, err)
+
+		return
+	}
+
+	if proposal != nil {
+		s.writeJSON(w, http.StatusAccepted, proposal)
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, updatedEntry)
+}
+
+// APIPatchEntry changes only the fields of an existing entry that are present in
+// the request body. If the body contains a Version, the change is rejected when
+// the entry is no longer at that version.
+func (s Server) APIPatchEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	entry, err := s.db.GetEntry(id)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if err = decodeEntry(r, entry); err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	s.updateEntryFromAPI(w, r, id, entry)
+}
+
+// APIDeleteEntry removes an entry from the plan and responds with the entry that
+// was removed, which can be restored until it is purged. If a Version is given
+// in the query, the entry is only removed if it is still at that version.
+func (s Server) APIDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	version, err := s.getVersionOrCurrent(r, id)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	entry, proposal, err := s.deleteEntry(r, id, version)
+	if err != nil {
+		s.writeChangeError(w, id, err)
+
+		return
+	}
+
+	if proposal != nil {
+		s.writeJSON(w, http.StatusAccepted, proposal)
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, entry)
+}
+
+// APIListDeleted responds with the deleted entries that can still be restored,
+// most recently deleted first.
+func (s Server) APIListDeleted(w http.ResponseWriter, _ *http.Request) {
+	deleted, err := s.db.ListDeleted()
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if deleted == nil {
+		deleted = []*sources.Entry{}
+	}
+
+	s.writeJSON(w, http.StatusOK, deleted)
+}
+
+// APIRestoreEntry brings back a deleted entry with the same ID and responds with
+// the restored entry, or with the proposal to restore it if that needs approval.
+func (s Server) APIRestoreEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	entry, proposal, err := s.restoreEntry(r, id)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if proposal != nil {
+		s.writeJSON(w, http.StatusAccepted, proposal)
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, entry)
+}
+
+func (s Server) getVersionOrCurrent(r *http.Request, id uint64) (uint32, error) {
+	if r.URL.Query().Has(Version.string()) {
+		version, err := getVersion(r)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", errInvalidQuery, err)
+		}
+
+		return version, nil
+	}
+
+	entry, err := s.db.GetEntry(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return entry.Version, nil
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/smarty/assertions"
+)
+
+func TestAPIListEntries(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/entries", nil)
+
+	s.APIListEntries(w, r)
+
+	var entries []*sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &entries)
+
+	if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAPIListEntriesPaged(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/entries?sort=reporting_name&order=desc&limit=1&offset=1", nil)
+
+	s.APIListEntries(w, r)
+
+	var entries []*sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &entries)
+
+	if ok, err := So(entries, ShouldResemble, []*sources.Entry{originalEntries[1]}); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(w.Header().Get(headerTotalCount), ShouldEqual, "3"); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/entries?offset=-1", nil)
+
+	s.APIListEntries(w, r)
+
+	var apiErr apiError
+	decodeJSONResponse(t, w, http.StatusBadRequest, &apiErr)
+}
+
+func TestAPIGetEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("You can get an existing entry", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIGetEntry(w, makeJSONRequest(http.MethodGet, fmt.Sprint(originalEntries[1].ID), ""))
+
+		var entry sources.Entry
+		decodeJSONResponse(t, w, http.StatusOK, &entry)
+
+		if ok, err := So(&entry, ShouldResemble, originalEntries[1]); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("A missing entry is reported as not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIGetEntry(w, makeJSONRequest(http.MethodGet, fmt.Sprint(sources.NumTestDataRows+100), ""))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusNotFound, &apiErr)
+
+		if ok, err := So(apiErr.Error, ShouldEqual, sources.ErrNoEntry.Error()); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("You must provide a valid ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIGetEntry(w, makeJSONRequest(http.MethodGet, "invalid ID", ""))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusBadRequest, &apiErr)
+
+		w = httptest.NewRecorder()
+
+		s.APIGetEntry(w, makeJSONRequest(http.MethodGet, "18446744073709551616", ""))
+
+		decodeJSONResponse(t, w, http.StatusBadRequest, &apiErr)
+	})
+}
+
+func TestAPIAddEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("You can add a valid entry", func(t *testing.T) {
+		newEntry := *originalEntries[0]
+		newEntry.ReportingName = "added_through_api"
+
+		w := httptest.NewRecorder()
+
+		s.APIAddEntry(w, makeJSONRequest(http.MethodPost, "", mustMarshal(t, newEntry)))
+
+		var added sources.Entry
+		decodeJSONResponse(t, w, http.StatusCreated, &added)
+
+		newEntry.ID = sources.NumTestDataRows
+		if ok, err := So(added, ShouldResemble, newEntry); !ok {
+			t.Error(err)
+		}
+
+		stored, err := s.db.GetEntry(added.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(*stored, ShouldResemble, newEntry); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Invalid entries are rejected with errors for each field", func(t *testing.T) {
+		newEntry := *originalEntries[0]
+		newEntry.ReportingName = ""
+		newEntry.Instruction = "invalid"
+
+		w := httptest.NewRecorder()
+
+		s.APIAddEntry(w, makeJSONRequest(http.MethodPost, "", mustMarshal(t, newEntry)))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusUnprocessableEntity, &apiErr)
+
+		expected := map[string]string{
+			ReportingName.string(): validation.ErrBlankInput,
+			Instruction.string():   "Input must be backup, nobackup or tempbackup",
+		}
+
+		if ok, err := So(apiErr.Fields, ShouldResemble, expected); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIAddEntry(w, makeJSONRequest(http.MethodPost, "", `{"Unknown": "field"}`))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusBadRequest, &apiErr)
+	})
+}
+
+func TestAPIReplaceEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	updated := *originalEntries[2]
+	updated.ID = 0 // the ID in the URL wins
+	updated.Requestor = "new_requestor"
+
+	w := httptest.NewRecorder()
+
+	s.APIReplaceEntry(w, makeJSONRequest(http.MethodPut, fmt.Sprint(originalEntries[2].ID), mustMarshal(t, updated)))
+
+	var entry sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &entry)
+
+	updated.ID = originalEntries[2].ID
+	updated.Version++
+
+	stored, err := s.db.GetEntry(updated.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(*stored, ShouldResemble, updated); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAPIPatchEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("Only the given fields are changed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIPatchEntry(w, makeJSONRequest(http.MethodPatch, fmt.Sprint(originalEntries[1].ID), `{"Faculty": "patched"}`))
+
+		var entry sources.Entry
+		decodeJSONResponse(t, w, http.StatusOK, &entry)
+
+		expected := *originalEntries[1]
+		expected.Faculty = "patched"
+		expected.Version++
+
+		if ok, err := So(entry, ShouldResemble, expected); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Patched entries are validated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIPatchEntry(w, makeJSONRequest(http.MethodPatch, fmt.Sprint(originalEntries[1].ID),
+			`{"Instruction": "nobackup", "Ignore": "*.txt"}`))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusUnprocessableEntity, &apiErr)
+
+		if ok, err := So(apiErr.Fields[Ignore.string()], ShouldEqual, validation.ErrIgnoreWithoutBackup); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("A missing entry is reported as not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		s.APIPatchEntry(w, makeJSONRequest(http.MethodPatch, fmt.Sprint(sources.NumTestDataRows+100), `{}`))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusNotFound, &apiErr)
+	})
+}
+
+func TestAPIDeleteEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	w := httptest.NewRecorder()
+
+	s.APIDeleteEntry(w, makeJSONRequest(http.MethodDelete, fmt.Sprint(originalEntries[0].ID), ""))
+
+	var deleted sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &deleted)
+
+	if ok, err := So(&deleted, ShouldResemble, originalEntries[0]); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+
+	s.APIDeleteEntry(w, makeJSONRequest(http.MethodDelete, fmt.Sprint(originalEntries[0].ID), ""))
+
+	var apiErr apiError
+	decodeJSONResponse(t, w, http.StatusNotFound, &apiErr)
+}
+
+func makeJSONRequest(method, id, body string) *http.Request {
+	r := httptest.NewRequest(method, "/api/v1/entries", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentTypeJSON)
+
+	ctx := chi.NewRouteContext()
+	ctx.URLParams.Add("id", id)
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func decodeJSONResponse(t *testing.T, w *httptest.ResponseRecorder, expectedStatus int, v any) {
+	t.Helper()
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	if ok, err := So(res.StatusCode, ShouldEqual, expectedStatus); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(res.Header.Get("Content-Type"), ShouldEqual, contentTypeJSON); !ok {
+		t.Error(err)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
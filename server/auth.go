@@ -0,0 +1,187 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// User is the person making a request, as identified by an Authenticator.
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// Authenticator identifies the user making a request.
+type Authenticator interface {
+	// Authenticate returns the user making the request, or an error wrapping
+	// ErrNotAuthenticated if they cannot be identified.
+	Authenticate(r *http.Request) (*User, error)
+
+	// Challenge responds to a request whose user could not be identified, for
+	// example by redirecting them to a login page.
+	Challenge(w http.ResponseWriter, r *http.Request)
+}
+
+var (
+	ErrNotAuthenticated = errors.New("not authenticated")
+	ErrUntrustedProxy   = errors.New("request did not come from a trusted proxy")
+)
+
+type userContextKey struct{}
+
+// getUser returns the user authenticated for the request, or nil if the server
+// has no Authenticator.
+func getUser(r *http.Request) *User {
+	user, _ := r.Context().Value(userContextKey{}).(*User)
+
+	return user
+}
+
+func withUser(r *http.Request, user *User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey{}, user))
+}
+
+// Authenticate is middleware identifying the user making each request, and
+// challenging requests whose user cannot be identified. Without an
+// Authenticator every request is allowed anonymously.
+func (s Server) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		user, err := s.auth.Authenticate(r)
+		if err != nil {
+			if !errors.Is(err, ErrNotAuthenticated) {
+				slog.Error(fmt.Sprintf("Failed to authenticate request for %s: %s", r.URL.Path, err))
+			}
+
+			s.auth.Challenge(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, withUser(r, user))
+	})
+}
+
+// setRequestor records the authenticated user as the requestor of a new entry,
+// so the entry cannot claim to be requested by someone else.
+func setRequestor(r *http.Request, entry *sources.Entry) {
+	if user := getUser(r); user != nil {
+		entry.Requestor = user.Name
+	}
+}
+
+// isAPIRequest returns true if the request was made to the JSON API, and so
+// should not be answered with redirects or HTML.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// HeaderAuthenticator trusts a reverse proxy to authenticate users and pass
+// their name, and optionally their comma separated groups, in request headers.
+// Requests from addresses outside TrustedProxies are refused, so the headers
+// cannot be forged by connecting to the server directly.
+type HeaderAuthenticator struct {
+	UserHeader     string
+	GroupsHeader   string
+	TrustedProxies []netip.Prefix
+}
+
+const (
+	DefaultUserHeader     = "X-Remote-User"
+	DefaultGroupsHeader   = "X-Remote-Groups"
+	DefaultTrustedProxies = "127.0.0.1/32,::1/128"
+)
+
+func (h HeaderAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	if !h.isTrusted(r.RemoteAddr) {
+		return nil, fmt.Errorf("%w: %w: %s", ErrNotAuthenticated, ErrUntrustedProxy, r.RemoteAddr)
+	}
+
+	name := strings.TrimSpace(r.Header.Get(h.UserHeader))
+	if name == "" {
+		return nil, fmt.Errorf("%w: no %s header", ErrNotAuthenticated, h.UserHeader)
+	}
+
+	user := &User{Name: name}
+
+	if h.GroupsHeader != "" {
+		for group := range strings.SplitSeq(r.Header.Get(h.GroupsHeader), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				user.Groups = append(user.Groups, group)
+			}
+		}
+	}
+
+	return user, nil
+}
+
+func (h HeaderAuthenticator) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range h.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h HeaderAuthenticator) Challenge(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, "Not authenticated", http.StatusUnauthorized)
+}
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and CIDR
+// prefixes.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestHeaderAuthenticator(t *testing.T) {
+	auth := HeaderAuthenticator{
+		UserHeader:     DefaultUserHeader,
+		GroupsHeader:   DefaultGroupsHeader,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		user         string
+		groups       string
+		expectedUser *User
+		expectedErr  error
+	}{
+		{
+			name:         "Users are identified by the proxy's headers",
+			remoteAddr:   "10.1.2.3:1234",
+			user:         "alice",
+			groups:       "hgi, ssg ,",
+			expectedUser: &User{Name: "alice", Groups: []string{"hgi", "ssg"}},
+		},
+		{
+			name:         "Groups are optional",
+			remoteAddr:   "10.1.2.3:1234",
+			user:         "alice",
+			expectedUser: &User{Name: "alice"},
+		},
+		{
+			name:        "Requests without a user are not authenticated",
+			remoteAddr:  "10.1.2.3:1234",
+			expectedErr: ErrNotAuthenticated,
+		},
+		{
+			name:        "Headers from untrusted addresses are ignored",
+			remoteAddr:  "192.168.0.1:1234",
+			user:        "alice",
+			expectedErr: ErrUntrustedProxy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set(DefaultUserHeader, tt.user)
+			r.Header.Set(DefaultGroupsHeader, tt.groups)
+
+			user, err := auth.Authenticate(r)
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+
+			if ok, err := So(user, ShouldResemble, tt.expectedUser); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies("127.0.0.1, 10.0.0.0/8,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+
+	if ok, err := So(prefixes, ShouldResemble, expected); !ok {
+		t.Error(err)
+	}
+
+	if _, err = ParseTrustedProxies("not an address"); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.auth = HeaderAuthenticator{UserHeader: DefaultUserHeader, TrustedProxies: []netip.Prefix{
+		netip.MustParsePrefix("192.0.2.0/24"), // httptest requests come from 192.0.2.1
+	}}
+
+	var seen *User
+
+	handler := s.Authenticate(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		seen = getUser(r)
+	}))
+
+	t.Run("Unauthenticated requests are refused", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusUnauthorized); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(seen, ShouldBeNil); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Authenticated users are passed on to the handler", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(DefaultUserHeader, "alice")
+
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if ok, err := So(seen, ShouldResemble, &User{Name: "alice"}); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("New entries are requested by the authenticated user", func(t *testing.T) {
+		newEntry := *originalEntries[0]
+		newEntry.Requestor = "someone_else"
+
+		r := makeFormRequest(createFormFromEntry(newEntry), "/actions/add", "")
+		r = withUser(r, &User{Name: "alice"})
+
+		s.AddNewEntry(httptest.NewRecorder(), r)
+
+		added, err := s.db.GetEntry(sources.NumTestDataRows)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(added.Requestor, ShouldEqual, "alice"); !ok {
+			t.Error(err)
+		}
+
+		history, err := s.db.GetHistory(added.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(history[0].Actor, ShouldEqual, "alice"); !ok {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"net/http"
+)
+
+const tmplConflictPath = "conflict_modal.html"
+
+type conflictTmplData struct {
+	Entry    *sources.Entry
+	Current  *sources.Entry
+	Changes  []fieldChange
+	Deleting bool
+}
+
+// showConflict opens a dialog explaining that someone else changed the entry
+// since the user started editing or deleting it, showing their values next to
+// the user's so the user can decide whether to overwrite them.
+func (s Server) showConflict(w http.ResponseWriter, entry *sources.Entry, deleting bool) {
+	current, err := s.db.GetEntry(entry.ID)
+	if err != nil && !errors.Is(err, sources.ErrNoEntry) {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	data := conflictTmplData{
+		Entry:    entry,
+		Current:  current,
+		Deleting: deleting,
+	}
+
+	if current != nil && !deleting {
+		data.Changes = diffEntries(current, entry)
+	}
+
+	if deleting {
+		w.Header().Set("HX-Retarget", "#modal")
+		w.Header().Set("HX-Reswap", "outerHTML")
+	} else {
+		w.Header().Set("HX-Retarget", "body")
+		w.Header().Set("HX-Reswap", "beforeend")
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplConflictPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestSubmitEditsConflict(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	theirs := *originalEntries[0]
+	theirs.Faculty = "their_faculty"
+
+	if err := s.db.UpdateEntry(&theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	mine := *originalEntries[0]
+	mine.Faculty = "my_faculty"
+
+	w := httptest.NewRecorder()
+	s.SubmitEdits(w, makeFormRequest(createFormFromEntry(mine), "/", fmt.Sprint(mine.ID)))
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	for _, expected := range []string{"Conflicting change", "their_faculty", "my_faculty"} {
+		if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+			t.Error(err)
+		}
+	}
+
+	if ok, err := So(w.Header().Get("HX-Retarget"), ShouldEqual, "body"); !ok {
+		t.Error(err)
+	}
+
+	stored, err := s.db.GetEntry(mine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(*stored, ShouldResemble, theirs); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAPIConflict(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	theirs := *originalEntries[2]
+	theirs.Requestor = "someone_else"
+
+	if err := s.db.UpdateEntry(&theirs); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Stale replacements are rejected", func(t *testing.T) {
+		mine := *originalEntries[2]
+		mine.Requestor = "me"
+
+		w := httptest.NewRecorder()
+		s.APIReplaceEntry(w, makeJSONRequest(http.MethodPut, fmt.Sprint(mine.ID), mustMarshal(t, mine)))
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusConflict, &apiErr)
+
+		if ok, err := So(apiErr.Current, ShouldResemble, &theirs); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Stale deletions are rejected", func(t *testing.T) {
+		r := makeJSONRequest(http.MethodDelete, fmt.Sprint(theirs.ID), "")
+		r.URL.RawQuery = fmt.Sprintf("%s=%d", Version, originalEntries[2].Version)
+
+		w := httptest.NewRecorder()
+		s.APIDeleteEntry(w, r)
+
+		var apiErr apiError
+		decodeJSONResponse(t, w, http.StatusConflict, &apiErr)
+
+		if _, err := s.db.GetEntry(theirs.ID); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Deletions without a version always succeed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.APIDeleteEntry(w, makeJSONRequest(http.MethodDelete, fmt.Sprint(theirs.ID), ""))
+
+		var deleted sources.Entry
+		decodeJSONResponse(t, w, http.StatusOK, &deleted)
+
+		if ok, err := So(deleted, ShouldResemble, theirs); !ok {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+const (
+	csrfCookieName  = "backup_plan_ui_csrf"
+	csrfHeader      = "X-CSRF-Token"
+	csrfTokenLength = 32
+)
+
+var (
+	ErrCrossOrigin       = errors.New("cross-origin request refused")
+	ErrInvalidCSRFToken  = errors.New("missing or invalid CSRF token")
+	ErrUnsupportedFormat = errors.New("request body must be " + contentTypeJSON)
+)
+
+type csrfContextKey struct{}
+
+// getCSRFToken returns the token the page rendered for the request must send
+// back with requests that change the plan.
+func getCSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+
+	return token
+}
+
+// ProtectCSRF is middleware refusing requests that change the plan unless they
+// were made by the UI itself. Each browser is given a random token in a cookie,
+// which pages send back in the X-CSRF-Token header of every htmx request; other
+// sites can neither read the cookie nor set the header. Requests that a browser
+// says came from another origin are refused outright.
+//
+// API requests do not need the token, as browsers will only send them
+// cross-origin after a CORS preflight this server never approves, provided that
+// their bodies are JSON.
+func ProtectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := csrfTokenFromCookie(r)
+		if token == "" {
+			var err error
+
+			token, err = newCSRFToken()
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to create CSRF token: %s", err))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   isHTTPS(r),
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token))
+
+		if isSafeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if err := checkMutation(r, token); err != nil {
+			status := http.StatusForbidden
+			if errors.Is(err, ErrUnsupportedFormat) {
+				status = http.StatusUnsupportedMediaType
+			}
+
+			slog.Warn(fmt.Sprintf("Refused %s %s: %s", r.Method, r.URL.Path, err))
+			http.Error(w, err.Error(), status)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func checkMutation(r *http.Request, token string) error {
+	if isCrossOrigin(r) {
+		return ErrCrossOrigin
+	}
+
+	if isAPIRequest(r) {
+		if r.ContentLength == 0 && r.Method == http.MethodDelete {
+			return nil
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != contentTypeJSON {
+			return ErrUnsupportedFormat
+		}
+
+		return nil
+	}
+
+	sent := r.Header.Get(csrfHeader)
+	if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		return ErrInvalidCSRFToken
+	}
+
+	return nil
+}
+
+func csrfTokenFromCookie(r *http.Request) string {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil {
+		return ""
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(decoded) != csrfTokenLength {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, csrfTokenLength)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCrossOrigin returns true if the browser making the request says it came
+// from a page of another origin. Requests from clients that are not browsers
+// send neither header, and are allowed.
+func isCrossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return false
+	case "":
+	default:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return true
+	}
+
+	return u.Host != r.Host && u.Host != r.Header.Get("X-Forwarded-Host")
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
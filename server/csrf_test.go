@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestProtectCSRF(t *testing.T) {
+	var reached bool
+
+	handler := ProtectCSRF(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		reached = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var cookie *http.Cookie
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			cookie = c
+		}
+	}
+
+	if cookie == nil {
+		t.Fatal("no CSRF cookie was set")
+	}
+
+	if ok, err := So(cookie.HttpOnly, ShouldBeTrue); !ok {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{"Safe requests are allowed", http.MethodGet, "/entries", "", nil, http.StatusOK},
+		{"Changes need the token", http.MethodDelete, "/actions/delete/1", "", nil, http.StatusForbidden},
+		{"Changes with a wrong token are refused", http.MethodPut, "/actions/add", "wrong", nil, http.StatusForbidden},
+		{"Changes with the token are allowed", http.MethodPut, "/actions/add", cookie.Value, nil, http.StatusOK},
+		{"Cross-site changes are refused", http.MethodPut, "/actions/add", cookie.Value,
+			map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"Changes from another origin are refused", http.MethodPut, "/actions/add", cookie.Value,
+			map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"Changes from the same origin are allowed", http.MethodPut, "/actions/add", cookie.Value,
+			map[string]string{"Origin": "http://example.com", "Sec-Fetch-Site": "same-origin"}, http.StatusOK},
+		{"API changes do not need the token", http.MethodPost, "/api/v1/entries", "",
+			map[string]string{"Content-Type": contentTypeJSON}, http.StatusOK},
+		{"API deletions do not need a body", http.MethodDelete, "/api/v1/entries/1", "", nil, http.StatusOK},
+		{"API changes must be JSON", http.MethodPost, "/api/v1/entries", "",
+			map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"Cross-site API changes are refused", http.MethodDelete, "/api/v1/entries/1", "",
+			map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ""
+			if tt.headers["Content-Type"] != "" {
+				body = "{}"
+			}
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			r.AddCookie(cookie)
+
+			if tt.token != "" {
+				r.Header.Set(csrfHeader, tt.token)
+			}
+
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			reached = false
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if ok, err := So(w.Code, ShouldEqual, tt.expectedStatus); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(reached, ShouldEqual, tt.expectedStatus == http.StatusOK); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestServeHomeSendsCSRFToken(t *testing.T) {
+	s, _ := createServer(t)
+
+	w := httptest.NewRecorder()
+	ProtectCSRF(http.HandlerFunc(s.ServeHome)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	var token string
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			token = c.Value
+		}
+	}
+
+	if ok, err := So(body, ShouldContainSubstring, `"X-CSRF-Token": "`+token+`"`); !ok {
+		t.Error(err)
+	}
+}
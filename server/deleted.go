@@ -0,0 +1,162 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	tmplUndoToastPath    = "undo_toast.html"
+	tmplDeletedModalPath = "deleted_modal.html"
+
+	// undoWindow is how long the toast offering to undo a deletion is shown.
+	undoWindow = 10 * time.Second
+)
+
+type undoTmplData struct {
+	Entry      *sources.Entry
+	UndoMillis int64
+}
+
+type deletedTmplData struct {
+	Rows          []tmplData
+	RetentionDays int
+}
+
+// restoreEntry brings back a deleted entry with its old ID and records it in its
+// history. If the user's changes need approval, the restore is proposed instead,
+// and the proposal returned.
+func (s Server) restoreEntry(r *http.Request, id uint64) (*sources.Entry, *sources.Proposal, error) {
+	deleted, err := s.db.ListDeleted()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	i := slices.IndexFunc(deleted, func(e *sources.Entry) bool { return e.ID == id })
+	if i == -1 {
+		if _, err = s.db.GetEntry(id); err == nil {
+			return nil, nil, sources.ErrNotDeleted
+		}
+
+		return nil, nil, err
+	}
+
+	if err = s.authorise(r, deleted[i].Faculty); err != nil {
+		return nil, nil, err
+	}
+
+	if s.needsApproval(r) {
+		proposal, err := s.propose(r, sources.OpRestore, nil, deleted[i])
+
+		return nil, proposal, err
+	}
+
+	entry, err := s.db.RestoreEntry(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Restored entry: %+v\n", *entry))
+
+	return entry, nil, s.recordHistory(r, sources.OpRestore, nil, entry)
+}
+
+// showUndo replaces the delete dialog with a toast that removes the deleted row
+// from the table and offers to restore it for a short while.
+func (s Server) showUndo(w http.ResponseWriter, entry *sources.Entry) {
+	data := undoTmplData{Entry: entry, UndoMillis: undoWindow.Milliseconds()}
+
+	if err := s.templates.ExecuteTemplate(w, tmplUndoToastPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// ShowDeleted opens a dialog listing the deleted entries that have not been
+// purged yet, most recently deleted first.
+func (s Server) ShowDeleted(w http.ResponseWriter, r *http.Request) {
+	deleted, err := s.db.ListDeleted()
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	data := deletedTmplData{RetentionDays: int(s.retention.Hours() / 24)}
+
+	for _, entry := range deleted {
+		data.Rows = append(data.Rows, s.rowData(r, entry))
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplDeletedModalPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// RestoreRow restores the deleted entry whose ID is given in the URL, and has
+// the table of entries reloaded to show it again. If the restore needs
+// approval, a toast saying it was proposed is added to the page instead.
+func (s Server) RestoreRow(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	_, proposal, err := s.restoreEntry(r, id)
+	if err != nil {
+		s.abortWithError(w, err, statusForError(err))
+
+		return
+	}
+
+	if proposal != nil {
+		w.Header().Set("HX-Retarget", "body")
+		w.Header().Set("HX-Reswap", "beforeend")
+		s.showProposed(w, proposal)
+
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "entriesChanged")
+}
+
+// PurgeDeletedEntries permanently removes the entries deleted longer ago than the
+// configured retention, and then again every interval until the context is
+// done. Deleted entries are kept forever if there is no retention.
+func (s Server) PurgeDeletedEntries(ctx context.Context, interval time.Duration) {
+	if s.retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeDeleted()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s Server) purgeDeleted() {
+	purged, err := s.db.PurgeDeleted(time.Now().Add(-s.retention))
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to purge deleted entries: %s", err))
+
+		return
+	}
+
+	if purged > 0 {
+		slog.Info(fmt.Sprintf("Purged %d entries deleted more than %s ago", purged, s.retention))
+	}
+}
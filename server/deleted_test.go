@@ -0,0 +1,151 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestDeleteAndRestoreRow(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	entry := originalEntries[0]
+
+	w := httptest.NewRecorder()
+	s.DeleteRow(w, makeVersionedRequest(entry.ID, entry.Version))
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	if ok, err := So(body, ShouldContainSubstring, fmt.Sprintf(`hx-post="actions/restore/%d"`, entry.ID)); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.ShowDeleted(w, makeRequest(entry.ID))
+
+	body = getBodyAndCheckStatusOK(t, w)
+
+	if ok, err := So(body, ShouldContainSubstring, entry.ReportingName); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.RestoreRow(w, makeRequest(entry.ID))
+
+	_ = getBodyAndCheckStatusOK(t, w)
+
+	if ok, err := So(w.Header().Get("HX-Trigger"), ShouldEqual, "entriesChanged"); !ok {
+		t.Error(err)
+	}
+
+	restored, err := s.db.GetEntry(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(restored, ShouldResemble, entry); !ok {
+		t.Error(err)
+	}
+
+	history, err := s.db.GetHistory(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(history, ShouldHaveLength, 2); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(history[1].Operation, ShouldEqual, sources.OpRestore); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.RestoreRow(w, makeRequest(entry.ID))
+
+	if ok, err := So(w.Code, ShouldEqual, http.StatusConflict); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAPIRestoreEntry(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	entry := originalEntries[1]
+
+	w := httptest.NewRecorder()
+	s.APIDeleteEntry(w, makeJSONRequest(http.MethodDelete, fmt.Sprint(entry.ID), ""))
+
+	var deleted sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &deleted)
+
+	w = httptest.NewRecorder()
+	s.APIListDeleted(w, makeJSONRequest(http.MethodGet, "", ""))
+
+	var listed []*sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &listed)
+
+	if ok, err := So(listed, ShouldHaveLength, 1); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(listed[0].DeletedAt, ShouldNotBeNil); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.APIRestoreEntry(w, makeJSONRequest(http.MethodPost, fmt.Sprint(entry.ID), ""))
+
+	var restored sources.Entry
+	decodeJSONResponse(t, w, http.StatusOK, &restored)
+
+	if ok, err := So(&restored, ShouldResemble, entry); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.APIRestoreEntry(w, makeJSONRequest(http.MethodPost, fmt.Sprint(sources.NumTestDataRows+100), ""))
+
+	var apiErr apiError
+	decodeJSONResponse(t, w, http.StatusNotFound, &apiErr)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.retention = time.Hour
+
+	for _, entry := range originalEntries[:2] {
+		if _, err := s.db.DeleteEntry(entry.ID, entry.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.purgeDeleted()
+
+	deleted, err := s.db.ListDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(deleted, ShouldHaveLength, 2); !ok {
+		t.Error(err)
+	}
+
+	s.retention = time.Nanosecond
+
+	s.purgeDeleted()
+
+	deleted, err = s.db.ListDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(deleted, ShouldBeEmpty); !ok {
+		t.Error(err)
+	}
+}
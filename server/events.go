@@ -0,0 +1,309 @@
+package server
+
+import (
+	"backup-plan-ui/health"
+	"backup-plan-ui/sources"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventEntryAdded is the name of the events carrying the rows of added and
+	// restored entries, which are inserted at the top of the table.
+	eventEntryAdded = "entryAdded"
+
+	// eventEntryPrefix prefixes the ID of an entry to make the name of the
+	// events replacing its row, or removing it if the entry was deleted.
+	eventEntryPrefix = "entry-"
+
+	// subscriberBuffer is how many changes can wait to be sent to a browser
+	// before it is considered too slow and disconnected, to reconnect afresh.
+	subscriberBuffer = 64
+
+	keepAliveInterval = 30 * time.Second
+)
+
+var errStreamingUnsupported = errors.New("streaming unsupported")
+
+// change is a change to an entry of the plan, to be shown to every browser.
+type change struct {
+	op       sources.Operation
+	entry    *sources.Entry
+	warnings []health.Issue
+}
+
+// changeHub passes every change made to the plan to every subscriber.
+type changeHub struct {
+	mu          sync.Mutex
+	subscribers map[chan change]struct{}
+
+	// latest is the last change published for each entry, so that changes
+	// found by watching the plan are not published again.
+	latest map[uint64]change
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{subscribers: make(map[chan change]struct{}), latest: make(map[uint64]change)}
+}
+
+// subscribe returns a channel receiving the changes published from now on, and
+// a function to stop receiving them. The channel is closed if the subscriber
+// falls too far behind.
+func (h *changeHub) subscribe() (<-chan change, func()) {
+	ch := make(chan change, subscriberBuffer)
+
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends the change to every subscriber, without waiting for them.
+func (h *changeHub) publish(c change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.latest[c.entry.ID] = c
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- c:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// published returns true if the last change published for the entry already
+// left it deleted, or at the same version and with the same fields, as the
+// given change does.
+func (h *changeHub) published(c change) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last, ok := h.latest[c.entry.ID]
+	if !ok {
+		return false
+	}
+
+	if c.op == sources.OpDelete || last.op == sources.OpDelete {
+		return c.op == last.op
+	}
+
+	return last.entry.Version == c.entry.Version && len(diffEntries(last.entry, c.entry)) == 0
+}
+
+// publishChange tells every browser about a change to the plan, described the
+// same way as in its history.
+func (s Server) publishChange(op sources.Operation, before, after *sources.Entry) {
+	if s.changes == nil {
+		return
+	}
+
+	s.changes.publish(s.newChange(op, before, after))
+}
+
+// publishUnseenChange tells every browser about a change to the plan found by
+// watching it, unless the change was already published when it was made.
+func (s Server) publishUnseenChange(op sources.Operation, before, after *sources.Entry) {
+	if s.changes == nil {
+		return
+	}
+
+	if c := s.newChange(op, before, after); !s.changes.published(c) {
+		s.changes.publish(c)
+	}
+}
+
+func (s Server) newChange(op sources.Operation, before, after *sources.Entry) change {
+	c := change{op: op, entry: after}
+
+	if after == nil {
+		c.entry = before
+	} else {
+		c.warnings = s.warningsFor(after.ID)
+	}
+
+	return c
+}
+
+// StreamChanges sends the browser a Server-Sent Event for every change to the
+// plan, carrying the row to show for the changed entry as the requesting user
+// would see it.
+func (s Server) StreamChanges(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || s.changes == nil {
+		s.abortWithError(w, errStreamingUnsupported, http.StatusInternalServerError)
+
+		return
+	}
+
+	changes, unsubscribe := s.changes.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case c, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			if err := s.writeChange(w, r, c); err != nil {
+				slog.Error(fmt.Sprintf("Failed to send change to entry %d: %s", c.entry.ID, err))
+
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// writeChange writes the change as an event, rendering its row for the user
+// making the request.
+func (s Server) writeChange(w http.ResponseWriter, r *http.Request, c change) error {
+	name := fmt.Sprintf("%s%d", eventEntryPrefix, c.entry.ID)
+
+	if c.op == sources.OpDelete {
+		return writeEvent(w, name, fmt.Sprintf("<!-- entry %d deleted -->", c.entry.ID))
+	}
+
+	if c.op == sources.OpAdd || c.op == sources.OpRestore {
+		name = eventEntryAdded
+	}
+
+	data := s.rowData(r, c.entry)
+	data.Warnings = c.warnings
+
+	var row bytes.Buffer
+
+	if err := s.templates.ExecuteTemplate(&row, tmplRowPath, data); err != nil {
+		return err
+	}
+
+	return writeEvent(w, name, row.String())
+}
+
+// writeEvent writes a Server-Sent Event, splitting its data over as many lines
+// as it has.
+func writeEvent(w http.ResponseWriter, name, data string) error {
+	var event strings.Builder
+
+	fmt.Fprintf(&event, "event: %s\n", name)
+
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&event, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+
+	event.WriteString("\n")
+
+	_, err := fmt.Fprint(w, event.String())
+
+	return err
+}
+
+// WatchForChanges reads the whole plan every interval until the context is
+// cancelled, telling every browser about the entries that were added, changed
+// or deleted since, including by other programs.
+func (s Server) WatchForChanges(ctx context.Context, interval time.Duration) {
+	known, err := s.entriesByID()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to read the plan to watch for changes: %s", err))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			known = s.checkForChanges(known)
+		}
+	}
+}
+
+// entriesByID returns the entries of the plan by ID.
+func (s Server) entriesByID() (map[uint64]*sources.Entry, error) {
+	entries, err := s.db.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint64]*sources.Entry, len(entries))
+
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+
+	return byID, nil
+}
+
+// checkForChanges publishes the differences between the known entries and
+// those of the plan now that were not already published when they were made,
+// and returns the latter. Entries count as changed if their version or any of
+// their fields differ, as the file may be edited by hand without changing the
+// version. It returns the known entries if the plan cannot be read.
+func (s Server) checkForChanges(known map[uint64]*sources.Entry) map[uint64]*sources.Entry {
+	current, err := s.entriesByID()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to read the plan to watch for changes: %s", err))
+
+		return known
+	}
+
+	if known == nil {
+		return current
+	}
+
+	for id, entry := range current {
+		before, ok := known[id]
+
+		switch {
+		case !ok:
+			s.publishUnseenChange(sources.OpAdd, nil, entry)
+		case before.Version != entry.Version || len(diffEntries(before, entry)) > 0:
+			s.publishUnseenChange(sources.OpUpdate, before, entry)
+		}
+	}
+
+	for id, entry := range known {
+		if _, ok := current[id]; !ok {
+			s.publishUnseenChange(sources.OpDelete, entry, nil)
+		}
+	}
+
+	return current
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gocarina/gocsv"
+	. "github.com/smarty/assertions"
+)
+
+func TestStreamChanges(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(s.StreamChanges))
+	t.Cleanup(ts.Close)
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { res.Body.Close() })
+
+	if ok, err := So(res.Header.Get("Content-Type"), ShouldEqual, "text/event-stream"); !ok {
+		t.Fatal(err)
+	}
+
+	events := bufio.NewReader(res.Body)
+
+	if name, _ := readEvent(t, events); name != "" {
+		t.Fatalf("expected a comment before any event, got %s", name)
+	}
+
+	entry := *originalEntries[0]
+	entry.ReportingName = "renamed"
+
+	if _, err = s.updateEntry(makeRequest(entry.ID), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	name, data := readEvent(t, events)
+
+	if ok, err := So(name, ShouldEqual, fmt.Sprintf("entry-%d", entry.ID)); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(data, ShouldContainSubstring, "renamed"); !ok {
+		t.Error(err)
+	}
+
+	if _, _, err = s.deleteEntry(makeRequest(entry.ID), entry.ID, entry.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	name, data = readEvent(t, events)
+
+	if ok, err := So(name, ShouldEqual, fmt.Sprintf("entry-%d", entry.ID)); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(data, ShouldNotContainSubstring, "<tr"); !ok {
+		t.Error(err)
+	}
+
+	added := *originalEntries[1]
+	added.ReportingName = "added"
+
+	if _, err = s.addEntry(makeRequest(0), &added); err != nil {
+		t.Fatal(err)
+	}
+
+	name, data = readEvent(t, events)
+
+	if ok, err := So(name, ShouldEqual, eventEntryAdded); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(data, ShouldContainSubstring, fmt.Sprintf(`data-id="%d"`, added.ID)); !ok {
+		t.Error(err)
+	}
+}
+
+// readEvent reads the next event, or comment, from the stream and returns its
+// name and data, the lines of which are joined by newlines.
+func readEvent(t *testing.T, events *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var name, data []string
+
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			return strings.Join(name, ""), strings.Join(data, "\n")
+		case strings.HasPrefix(line, "event: "):
+			name = append(name, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
+
+func TestCheckForChanges(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	known := s.checkForChanges(nil)
+
+	changes, unsubscribe := s.changes.subscribe()
+	t.Cleanup(unsubscribe)
+
+	updated := *originalEntries[0]
+	updated.Requestor = "someone_else"
+
+	if err := s.db.UpdateEntry(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.db.DeleteEntry(originalEntries[1].ID, originalEntries[1].Version); err != nil {
+		t.Fatal(err)
+	}
+
+	known = s.checkForChanges(known)
+
+	if ok, err := So(known, ShouldHaveLength, sources.NumTestDataRows-1); !ok {
+		t.Error(err)
+	}
+
+	seen := make(map[sources.Operation]uint64)
+
+	for range 2 {
+		c := <-changes
+		seen[c.op] = c.entry.ID
+	}
+
+	if ok, err := So(seen, ShouldResemble, map[sources.Operation]uint64{
+		sources.OpUpdate: updated.ID,
+		sources.OpDelete: originalEntries[1].ID,
+	}); !ok {
+		t.Error(err)
+	}
+
+	s.checkForChanges(known)
+
+	select {
+	case c := <-changes:
+		t.Errorf("expected no more changes, got %+v", c)
+	default:
+	}
+}
+
+func TestCheckForChangesWithoutNewVersion(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	known := s.checkForChanges(nil)
+
+	changes, unsubscribe := s.changes.subscribe()
+	t.Cleanup(unsubscribe)
+
+	edited := make([]*sources.Entry, len(originalEntries))
+	for i, entry := range originalEntries {
+		e := *entry
+		edited[i] = &e
+	}
+
+	edited[0].Requestor = "someone_else"
+
+	data, err := gocsv.MarshalBytes(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(s.db.(sources.CSVSource).Path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s.checkForChanges(known)
+
+	select {
+	case c := <-changes:
+		if ok, err := So(c.op, ShouldEqual, sources.OpUpdate); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(c.entry, ShouldResemble, edited[0]); !ok {
+			t.Error(err)
+		}
+	default:
+		t.Fatal("expected the edit to be published")
+	}
+}
+
+func TestCheckForChangesSkipsPublished(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	known := s.checkForChanges(nil)
+
+	changes, unsubscribe := s.changes.subscribe()
+	t.Cleanup(unsubscribe)
+
+	updated := *originalEntries[0]
+	updated.Requestor = "someone_else"
+
+	if _, err := s.updateEntry(httptest.NewRequest(http.MethodPut, "/", nil), &updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.deleteEntry(httptest.NewRequest(http.MethodDelete, "/", nil), originalEntries[1].ID,
+		originalEntries[1].Version); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		<-changes
+	}
+
+	s.checkForChanges(known)
+
+	select {
+	case c := <-changes:
+		t.Errorf("expected changes to be published once, got %+v again", c)
+	default:
+	}
+}
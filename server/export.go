@@ -0,0 +1,123 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gocarina/gocsv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	headerGeneratedAt = "X-Generated-At"
+	exportTimeFormat  = "20060102T150405Z"
+)
+
+var ErrUnknownExportFormat = errors.New("unknown export format, expected csv, json or yaml")
+
+// exportDocument is what the JSON and YAML exports contain.
+type exportDocument struct {
+	GeneratedAt time.Time        `json:"generated_at" yaml:"generated_at"`
+	Entries     []*sources.Entry `json:"entries" yaml:"entries"`
+}
+
+// exportFormat is a format the plan can be exported in.
+type exportFormat struct {
+	contentType string
+	write       func(w io.Writer, doc exportDocument) error
+}
+
+// exportFormats are the formats the plan can be exported in, by file extension.
+// CSV exports have the columns of the CSV backend, so they can be used as a plan
+// themselves, and give the time they were generated in their file name only.
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		write: func(w io.Writer, doc exportDocument) error {
+			return gocsv.Marshal(doc.Entries, w)
+		},
+	},
+	"json": {
+		contentType: contentTypeJSON,
+		write: func(w io.Writer, doc exportDocument) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(doc)
+		},
+	},
+	"yaml": {
+		contentType: "application/yaml",
+		write: func(w io.Writer, doc exportDocument) error {
+			enc := yaml.NewEncoder(w)
+			defer enc.Close()
+
+			return enc.Encode(doc)
+		},
+	},
+}
+
+// ExportEntries downloads the entries selected by the request's query, all of
+// them by default, in the format named in the URL. They are ordered as in the
+// table: by ID unless another column to sort by is given. With a snapshot in the
+// query, the entries of that published snapshot, or the latest, are exported
+// instead of the working copy.
+func (s Server) ExportEntries(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "format")
+
+	format, ok := exportFormats[name]
+	if !ok {
+		s.abortWithError(w, fmt.Errorf("%w: %q", ErrUnknownExportFormat, name), http.StatusNotFound)
+
+		return
+	}
+
+	query, err := queryFromRequest(r, 0)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	doc := exportDocument{GeneratedAt: time.Now().UTC().Truncate(time.Second)}
+	fileName := "backup-plan-" + doc.GeneratedAt.Format(exportTimeFormat)
+
+	var page *sources.Page
+
+	if ref := r.URL.Query().Get(querySnapshot); ref != "" {
+		var snapshot *sources.Snapshot
+
+		if snapshot, err = s.getSnapshot(ref); err == nil {
+			doc.GeneratedAt = snapshot.PublishedAt.Truncate(time.Second)
+			fileName = fmt.Sprintf("backup-plan-snapshot-%d", snapshot.Number)
+			page, err = query.Apply(snapshot.Entries)
+		}
+	} else {
+		page, err = s.db.Query(query)
+	}
+
+	if err != nil {
+		s.abortWithError(w, err, statusForError(err))
+
+		return
+	}
+
+	doc.Entries = page.Entries
+	if doc.Entries == nil {
+		doc.Entries = []*sources.Entry{}
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set(headerGeneratedAt, doc.GeneratedAt.Format(time.RFC3339))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, fileName, name))
+
+	if err = format.write(w, doc); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
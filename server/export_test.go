@@ -0,0 +1,99 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gocarina/gocsv"
+	. "github.com/smarty/assertions"
+	"gopkg.in/yaml.v3"
+)
+
+func makeExportRequest(format, query string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/export/"+format+query, nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("format", format)
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestExportEntries(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("CSV exports have the columns of the CSV backend", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ExportEntries(w, makeExportRequest("csv", ""))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		var entries []*sources.Entry
+		if err := gocsv.UnmarshalString(body, &entries); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(w.Header().Get("Content-Disposition"), ShouldEndWith, `.csv"`); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(w.Header().Get(headerGeneratedAt), ShouldNotBeBlank); !ok {
+			t.Error(err)
+		}
+	})
+
+	for format, unmarshal := range map[string]func([]byte, any) error{
+		"json": json.Unmarshal,
+		"yaml": yaml.Unmarshal,
+	} {
+		t.Run(format+" exports say when they were generated", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ExportEntries(w, makeExportRequest(format, "?sort=reporting_name&order=desc"))
+
+			var doc exportDocument
+			if err := unmarshal([]byte(getBodyAndCheckStatusOK(t, w)), &doc); err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(doc.GeneratedAt.IsZero(), ShouldBeFalse); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(doc.Entries, ShouldHaveLength, len(originalEntries)); !ok {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(doc.Entries[0], ShouldResemble, originalEntries[len(originalEntries)-1]); !ok {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("Exports can be filtered", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ExportEntries(w, makeExportRequest("json", "?search="+originalEntries[1].ReportingName))
+
+		var doc exportDocument
+		decodeJSONResponse(t, w, http.StatusOK, &doc)
+
+		if ok, err := So(doc.Entries, ShouldResemble, []*sources.Entry{originalEntries[1]}); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Unknown formats are not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ExportEntries(w, makeExportRequest("xlsx", ""))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusNotFound); !ok {
+			t.Error(err)
+		}
+	})
+}
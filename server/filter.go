@@ -0,0 +1,94 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	querySearch      = "search"
+	queryInstruction = "instruction"
+	queryFaculty     = "faculty"
+	queryRequestor   = "requestor"
+	queryRootPrefix  = "root"
+	querySort        = "sort"
+	queryOrder       = "order"
+	queryLimit       = "limit"
+	queryOffset      = "offset"
+
+	orderDescending = "desc"
+
+	// entriesPageSize is the number of rows shown at a time in the table; more are
+	// loaded as the user scrolls down.
+	entriesPageSize = 100
+
+	tmplMoreEntriesPath = "more_entries.html"
+)
+
+// filterFromQuery creates a filter from the search, filter and sort parameters
+// in the URL query of the request.
+func filterFromQuery(r *http.Request) *sources.Filter {
+	query := r.URL.Query()
+
+	return &sources.Filter{
+		Search:              strings.TrimSpace(query.Get(querySearch)),
+		Instruction:         sources.Instruction(query.Get(queryInstruction)),
+		Faculty:             strings.TrimSpace(query.Get(queryFaculty)),
+		Requestor:           strings.TrimSpace(query.Get(queryRequestor)),
+		ReportingRootPrefix: strings.TrimSpace(query.Get(queryRootPrefix)),
+		SortBy:              query.Get(querySort),
+		Descending:          query.Get(queryOrder) == orderDescending,
+	}
+}
+
+// queryFromRequest creates a query from the filter and page parameters in the
+// URL query of the request, using defaultLimit if no limit is given.
+func queryFromRequest(r *http.Request, defaultLimit int) (*sources.Query, error) {
+	query := &sources.Query{
+		Filter: *filterFromQuery(r),
+		Limit:  defaultLimit,
+	}
+
+	params := r.URL.Query()
+
+	var err error
+
+	if params.Has(queryLimit) {
+		if query.Limit, err = strconv.Atoi(params.Get(queryLimit)); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidQuery, err)
+		}
+	}
+
+	if params.Has(queryOffset) {
+		if query.Offset, err = strconv.Atoi(params.Get(queryOffset)); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidQuery, err)
+		}
+	}
+
+	return query, nil
+}
+
+func isQueryError(err error) bool {
+	return errors.Is(err, errInvalidQuery) || errors.Is(err, sources.ErrInvalidSortColumn) ||
+		errors.Is(err, sources.ErrInvalidPage)
+}
+
+// nextPageURL returns the URL of the page of entries following one with the given
+// number of entries, keeping the rest of the request's query.
+func nextPageURL(r *http.Request, query *sources.Query, numEntries int) string {
+	params := make(url.Values)
+
+	for key, values := range r.URL.Query() {
+		params[key] = values
+	}
+
+	params.Set(queryOffset, strconv.Itoa(query.Offset+numEntries))
+	params.Set(queryLimit, strconv.Itoa(query.Limit))
+
+	return "entries?" + params.Encode()
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestGetEntriesFiltered(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("Only matching entries are shown", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/entries?search="+originalEntries[1].ReportingName, nil)
+		w := httptest.NewRecorder()
+
+		s.GetEntries(w, req)
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, originalEntries[1].ReportingName); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(body, ShouldNotContainSubstring, originalEntries[0].ReportingName); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Entries can be sorted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/entries?sort=reporting_name&order=desc", nil)
+		w := httptest.NewRecorder()
+
+		s.GetEntries(w, req)
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldStartWith, `<tr data-id="2"`); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Unknown sort columns are rejected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/entries?sort=unknown", nil)
+		w := httptest.NewRecorder()
+
+		s.GetEntries(w, req)
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+			t.Error(err)
+		}
+	})
+}
+
+func TestFilterFromQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet,
+		"/entries?search=+text+&instruction=nobackup&faculty=hgi&requestor=me&root=/lustre&sort=faculty&order=desc", nil)
+
+	filter := filterFromQuery(req)
+
+	if ok, err := So(filter.Search, ShouldEqual, "text"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(string(filter.Instruction), ShouldEqual, "nobackup"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(filter.Faculty+filter.Requestor+filter.ReportingRootPrefix, ShouldEqual, "hgime/lustre"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(filter.SortBy, ShouldEqual, "faculty"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(filter.Descending, ShouldBeTrue); !ok {
+		t.Error(err)
+	}
+}
+
+func TestGetEntriesPaged(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/entries?limit=2&faculty="+originalEntries[0].Faculty, nil)
+	w := httptest.NewRecorder()
+
+	s.GetEntries(w, req)
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	for i, entry := range originalEntries {
+		if ok, err := So(strings.Contains(body, entry.ReportingName), ShouldEqual, i < 2); !ok {
+			t.Error(err)
+		}
+	}
+
+	if ok, err := So(body, ShouldContainSubstring, `hx-get="entries?faculty=group&amp;limit=2&amp;offset=2"`); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(w.Header().Get("HX-Trigger"), ShouldContainSubstring, `"shown": 2, "total": 3`); !ok {
+		t.Error(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/entries?limit=2&offset=2", nil)
+	w = httptest.NewRecorder()
+
+	s.GetEntries(w, req)
+
+	body = getBodyAndCheckStatusOK(t, w)
+
+	if ok, err := So(body, ShouldContainSubstring, originalEntries[2].ReportingName); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(body, ShouldNotContainSubstring, "load-more"); !ok {
+		t.Error(err)
+	}
+}
+
+func TestQueryFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/entries?limit=10&offset=20", nil)
+
+	query, err := queryFromRequest(req, entriesPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(query.Limit, ShouldEqual, 10); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(query.Offset, ShouldEqual, 20); !ok {
+		t.Error(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/entries", nil)
+
+	query, err = queryFromRequest(req, entriesPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(query.Limit, ShouldEqual, entriesPageSize); !ok {
+		t.Error(err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/entries?limit=many", nil)
+
+	_, err = queryFromRequest(req, entriesPageSize)
+	if ok, err := So(isQueryError(err), ShouldBeTrue); !ok {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"backup-plan-ui/health"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	tmplWarningsPath   = "warnings.html"
+	tmplPlanHealthPath = "plan_health.html"
+)
+
+type planHealthTmplData struct {
+	User   *User
+	Issues []health.Issue
+}
+
+// planIssues returns the issues with the whole plan.
+func (s Server) planIssues() ([]health.Issue, error) {
+	entries, err := s.db.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return health.Check(entries), nil
+}
+
+// warningsFor returns the issues with the plan involving the entry with the
+// given ID, to warn the user who just saved it. Failing to find them does not
+// stop the entry being saved, so errors are only logged.
+func (s Server) warningsFor(id uint64) []health.Issue {
+	issues, err := s.planIssues()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to check the plan for issues: %s", err))
+
+		return nil
+	}
+
+	return health.ForEntry(issues, id)
+}
+
+// ShowPlanHealth renders a page listing every issue with the plan.
+func (s Server) ShowPlanHealth(w http.ResponseWriter, r *http.Request) {
+	issues, err := s.planIssues()
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	data := planHealthTmplData{User: getUser(r), Issues: issues}
+
+	if err = s.templates.ExecuteTemplate(w, tmplPlanHealthPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// APIPlanHealth responds with every issue with the plan as a JSON array.
+func (s Server) APIPlanHealth(w http.ResponseWriter, _ *http.Request) {
+	issues, err := s.planIssues()
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if issues == nil {
+		issues = []health.Issue{}
+	}
+
+	s.writeJSON(w, http.StatusOK, issues)
+}
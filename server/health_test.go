@@ -0,0 +1,59 @@
+package server
+
+import (
+	"backup-plan-ui/health"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestPlanHealth(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	// the test entries all have the same directory
+	duplicates := len(originalEntries) - 1
+
+	t.Run("Saving a row shows the issues it is involved in", func(t *testing.T) {
+		entry := *originalEntries[1]
+		entry.ReportingName = "renamed"
+
+		w := httptest.NewRecorder()
+		s.SubmitEdits(w, makeFormRequest(createFormFromEntry(entry), "/", fmt.Sprint(entry.ID)))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "already has an entry"); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("The plan health page lists every issue", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ShowPlanHealth(w, httptest.NewRequest(http.MethodGet, "/plan-health", nil))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, fmt.Sprintf("%d rules", duplicates)); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("The API lists every issue", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.APIPlanHealth(w, httptest.NewRequest(http.MethodGet, "/api/v1/plan-health", nil))
+
+		var issues []health.Issue
+		decodeJSONResponse(t, w, http.StatusOK, &issues)
+
+		if ok, err := So(issues, ShouldHaveLength, duplicates); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(issues[0].Kind, ShouldEqual, health.Duplicate); !ok {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+)
+
+const tmplHistoryPath = "history_modal.html"
+
+var errHistoryNotRecorded = errors.New("the change was made, but could not be recorded in its history")
+
+var entryFields = []formField{ReportingName, ReportingRoot, Directory, Instruction, Match, Ignore, Requestor, Faculty}
+
+type fieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+type historyView struct {
+	*sources.HistoryRecord
+	Changes []fieldChange
+}
+
+type historyTmplData struct {
+	EntryID uint64
+	Records []historyView
+}
+
+// getActor returns a description of who made the request, to be recorded in the
+// history of any entries it changes: the authenticated user, or the address the
+// request came from if the server has no Authenticator.
+func getActor(r *http.Request) string {
+	if user := getUser(r); user != nil {
+		return user.Name
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// recordHistory records a change to the plan in its history, and tells every
+// browser about it. The change has been made whether or not it could be
+// recorded.
+func (s Server) recordHistory(r *http.Request, op sources.Operation, before, after *sources.Entry) error {
+	s.publishChange(op, before, after)
+
+	record := sources.NewHistoryRecord(op, getActor(r), before, after)
+
+	if err := s.db.AddHistory(record); err != nil {
+		slog.Error(fmt.Sprintf("Failed to record history %+v: %s", *record, err))
+
+		return fmt.Errorf("%w: %w", errHistoryNotRecorded, err)
+	}
+
+	return nil
+}
+
+// addEntry adds the entry to the plan and records the addition in its history.
+// If the user's changes need approval, the addition is proposed instead, and the
+// proposal returned.
+func (s Server) addEntry(r *http.Request, entry *sources.Entry) (*sources.Proposal, error) {
+	if err := s.authorise(r, entry.Faculty); err != nil {
+		return nil, err
+	}
+
+	if s.needsApproval(r) {
+		return s.propose(r, sources.OpAdd, nil, entry)
+	}
+
+	if err := s.db.AddEntry(entry); err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Added entry: %+v\n", *entry))
+
+	return nil, s.recordHistory(r, sources.OpAdd, nil, entry)
+}
+
+// updateEntry replaces the stored entry with the same ID and records the values
+// before and after the change in its history. If the user's changes need
+// approval, the change is proposed instead, and the proposal returned.
+func (s Server) updateEntry(r *http.Request, entry *sources.Entry) (*sources.Proposal, error) {
+	before, err := s.db.GetEntry(entry.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.authorise(r, before.Faculty, entry.Faculty); err != nil {
+		return nil, err
+	}
+
+	if s.needsApproval(r) {
+		if before.Version != entry.Version {
+			return nil, sources.ErrVersionConflict
+		}
+
+		return s.propose(r, sources.OpUpdate, before, entry)
+	}
+
+	if err = s.db.UpdateEntry(entry); err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Updated entry: %+v\n", *entry))
+
+	return nil, s.recordHistory(r, sources.OpUpdate, before, entry)
+}
+
+// deleteEntry removes the entry with the given ID, as long as it is still at the
+// given version, and records its last values in its history. If the user's
+// changes need approval, the deletion is proposed instead, and the proposal
+// returned along with the entry, which is left as it is.
+func (s Server) deleteEntry(r *http.Request, id uint64, version uint32) (*sources.Entry, *sources.Proposal, error) {
+	current, err := s.db.GetEntry(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = s.authorise(r, current.Faculty); err != nil {
+		return nil, nil, err
+	}
+
+	if s.needsApproval(r) {
+		if current.Version != version {
+			return nil, nil, sources.ErrVersionConflict
+		}
+
+		proposal, err := s.propose(r, sources.OpDelete, current, nil)
+
+		return current, proposal, err
+	}
+
+	entry, err := s.db.DeleteEntry(id, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Deleted entry: %+v\n", *entry))
+
+	return entry, nil, s.recordHistory(r, sources.OpDelete, entry, nil)
+}
+
+// ShowHistory opens a dialog listing every recorded change to an entry, newest
+// first.
+func (s Server) ShowHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	records, err := s.db.GetHistory(id)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	data := historyTmplData{EntryID: id}
+
+	for _, record := range slices.Backward(records) {
+		data.Records = append(data.Records, historyView{
+			HistoryRecord: record,
+			Changes:       diffEntries(record.Before, record.After),
+		})
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplHistoryPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// diffEntries lists the fields whose values differ between before and after,
+// either of which may be nil.
+func diffEntries(before, after *sources.Entry) []fieldChange {
+	beforeValues := valuesFromEntry(orEmptyEntry(before))
+	afterValues := valuesFromEntry(orEmptyEntry(after))
+
+	var changes []fieldChange
+
+	for _, field := range entryFields {
+		change := fieldChange{
+			Field:  field.string(),
+			Before: beforeValues.Get(field.string()),
+			After:  afterValues.Get(field.string()),
+		}
+
+		if change.Before != change.After {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+func orEmptyEntry(entry *sources.Entry) *sources.Entry {
+	if entry == nil {
+		return &sources.Entry{}
+	}
+
+	return entry
+}
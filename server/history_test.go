@@ -0,0 +1,147 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestHistoryIsRecorded(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	entry := *originalEntries[0]
+	entry.Instruction = sources.NoBackup
+
+	w := httptest.NewRecorder()
+	s.SubmitEdits(w, makeFormRequest(createFormFromEntry(entry), "/", fmt.Sprint(entry.ID)))
+	_ = getBodyAndCheckStatusOK(t, w)
+
+	w = httptest.NewRecorder()
+	s.DeleteRow(w, makeVersionedRequest(entry.ID, entry.Version+1))
+	_ = getBodyAndCheckStatusOK(t, w)
+
+	history, err := s.db.GetHistory(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(history, ShouldHaveLength, 2); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(history[0].Operation, ShouldEqual, sources.OpUpdate); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(history[0].Before, ShouldResemble, originalEntries[0]); !ok {
+		t.Error(err)
+	}
+
+	entry.Version++
+
+	if ok, err := So(history[0].After, ShouldResemble, &entry); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(history[1].Operation, ShouldEqual, sources.OpDelete); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(history[1].After, ShouldBeNil); !ok {
+		t.Error(err)
+	}
+
+	for _, record := range history {
+		if ok, err := So(record.Actor, ShouldEqual, "192.0.2.1"); !ok {
+			t.Error(err)
+		}
+	}
+}
+
+// failingHistory is a data source whose history cannot be written.
+type failingHistory struct {
+	sources.DataSource
+}
+
+func (failingHistory) AddHistory(*sources.HistoryRecord) error {
+	return errors.New("history is read-only")
+}
+
+func TestHistoryErrorsAreReturned(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.db = failingHistory{s.db}
+
+	entry := *originalEntries[0]
+	entry.Instruction = sources.NoBackup
+
+	w := httptest.NewRecorder()
+	s.APIReplaceEntry(w, makeJSONRequest(http.MethodPut, fmt.Sprint(entry.ID), mustMarshal(t, entry)))
+
+	if ok, err := So(w.Code, ShouldEqual, http.StatusInternalServerError); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(w.Body.String(), ShouldContainSubstring, errHistoryNotRecorded.Error()); !ok {
+		t.Error(err)
+	}
+
+	stored, err := s.db.GetEntry(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored.Instruction, ShouldEqual, sources.NoBackup); !ok {
+		t.Error(err)
+	}
+}
+
+func TestShowHistory(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	entry := *originalEntries[1]
+	entry.Faculty = "new_faculty"
+
+	w := httptest.NewRecorder()
+	s.SubmitEdits(w, makeFormRequest(createFormFromEntry(entry), "/", fmt.Sprint(entry.ID)))
+	_ = getBodyAndCheckStatusOK(t, w)
+
+	w = httptest.NewRecorder()
+	s.ShowHistory(w, makeRequest(entry.ID))
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	for _, expected := range []string{
+		string(sources.OpUpdate), Faculty.string(), originalEntries[1].Faculty, "new_faculty",
+	} {
+		if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+			t.Error(err)
+		}
+	}
+
+	if ok, err := So(body, ShouldNotContainSubstring, ReportingRoot.string()); !ok {
+		t.Error(err)
+	}
+}
+
+func TestDiffEntries(t *testing.T) {
+	before := &sources.Entry{ReportingName: "name", Instruction: sources.Backup, Match: "*.txt"}
+	after := &sources.Entry{ReportingName: "name", Instruction: sources.NoBackup}
+
+	expected := []fieldChange{
+		{Field: Instruction.string(), Before: string(sources.Backup), After: string(sources.NoBackup)},
+		{Field: Match.string(), Before: "*.txt", After: ""},
+	}
+
+	if ok, err := So(diffEntries(before, after), ShouldResemble, expected); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(diffEntries(nil, after), ShouldHaveLength, 2); !ok {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"backup-plan-ui/importer"
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	tmplImportPath     = "import.html"
+	tmplImportDiffPath = "import_diff.html"
+
+	importFileField     = "File"
+	importCSVField      = "CSV"
+	removeMissingField  = "RemoveMissing"
+	acceptedChangeField = "Accept"
+
+	// maxImportSize is the largest CSV file that can be imported.
+	maxImportSize = 8 << 20
+)
+
+var (
+	errNoImportFile      = errors.New("choose a CSV file to import")
+	errNoChangesAccepted = errors.New("select the changes to make")
+)
+
+// importOperations are the operations on the plan each kind of change makes.
+var importOperations = map[sources.ChangeKind]sources.Operation{
+	sources.ChangeAdd:    sources.OpAdd,
+	sources.ChangeUpdate: sources.OpUpdate,
+	sources.ChangeRemove: sources.OpDelete,
+}
+
+type importTmplData struct {
+	User      *User
+	CSRFToken string
+}
+
+// importChange is a change an imported file would make, with the fields it
+// changes.
+type importChange struct {
+	*importer.Change
+	Fields []fieldChange
+}
+
+type importDiffTmplData struct {
+	CSV           string
+	RemoveMissing bool
+	Changes       []importChange
+	Unchanged     int
+	Applied       int
+	Proposed      int
+	Message       string
+}
+
+// ShowImport renders a page for uploading a CSV file of entries, to see the
+// changes importing it would make to the plan before making them.
+func (s Server) ShowImport(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageAny(r) {
+		s.abortWithError(w, ErrForbidden, http.StatusForbidden)
+
+		return
+	}
+
+	data := importTmplData{User: getUser(r), CSRFToken: getCSRFToken(r)}
+
+	if err := s.templates.ExecuteTemplate(w, tmplImportPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// PreviewImport shows the changes the uploaded CSV file would make to the plan,
+// with the reasons any of them cannot be made.
+func (s Server) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageAny(r) {
+		s.abortWithError(w, ErrForbidden, http.StatusForbidden)
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	csv, err := readImportFile(r)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	data := importDiffTmplData{CSV: csv, RemoveMissing: r.FormValue(removeMissingField) != ""}
+
+	s.renderImportDiff(w, r, data)
+}
+
+// readImportFile returns the contents of the uploaded CSV file.
+func readImportFile(r *http.Request) (string, error) {
+	file, _, err := r.FormFile(importFileField)
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return "", errNoImportFile
+	} else if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	contents, err := io.ReadAll(file)
+
+	return string(contents), err
+}
+
+// ApplyImport makes the accepted changes of an imported CSV file together, or
+// none of them if any cannot be made. The file is compared with the plan again
+// first, and if the plan has changed since the changes were shown, the new
+// changes are shown instead of making any.
+func (s Server) ApplyImport(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageAny(r) {
+		s.abortWithError(w, ErrForbidden, http.StatusForbidden)
+
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	data := importDiffTmplData{
+		CSV:           r.PostFormValue(importCSVField),
+		RemoveMissing: r.PostFormValue(removeMissingField) != "",
+	}
+
+	diff, err := s.compareImport(r, data.CSV, data.RemoveMissing)
+
+	switch {
+	case err != nil:
+	case s.needsApproval(r):
+		data.Proposed, err = s.proposeImport(r, diff, r.PostForm[acceptedChangeField])
+	default:
+		data.Applied, err = s.applyImport(r, diff, r.PostForm[acceptedChangeField])
+	}
+
+	switch {
+	case errors.Is(err, errHistoryNotRecorded):
+		data.Message = err.Error()
+	case err != nil:
+		slog.Warn(fmt.Sprintf("Refused to import changes: %s", err))
+
+		data.Message = fmt.Sprintf("No changes were made: %s", err)
+	}
+
+	s.renderImportDiff(w, r, data)
+}
+
+// applyImport makes the changes in the diff with the given keys, recording each
+// in the history of the plan, and returns how many were made.
+func (s Server) applyImport(r *http.Request, diff *importer.Diff, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, errNoChangesAccepted
+	}
+
+	set, accepted, err := s.acceptImport(r, diff, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.db.ApplyChanges(set); err != nil {
+		return 0, err
+	}
+
+	slog.Info(fmt.Sprintf("Imported %d changes: added %d, updated %d, deleted %d",
+		len(accepted), len(set.Add), len(set.Update), len(set.Delete)))
+
+	var errs []error
+
+	for _, change := range accepted {
+		if err = s.recordHistory(r, importOperations[change.Kind], change.Before, change.After); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return len(accepted), errors.Join(errs...)
+}
+
+// proposeImport sends the changes in the diff with the given keys to an admin
+// for review, and returns how many were sent.
+func (s Server) proposeImport(r *http.Request, diff *importer.Diff, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, errNoChangesAccepted
+	}
+
+	_, accepted, err := s.acceptImport(r, diff, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, change := range accepted {
+		if _, err = s.propose(r, importOperations[change.Kind], change.Before, change.After); err != nil {
+			return i, err
+		}
+	}
+
+	return len(accepted), nil
+}
+
+// acceptImport returns the changes in the diff with the given keys, and a
+// ChangeSet making them, as long as the user may change the entries of every
+// faculty they are about.
+func (s Server) acceptImport(r *http.Request, diff *importer.Diff, keys []string) (*sources.ChangeSet,
+	[]*importer.Change, error) {
+	set, accepted, err := diff.Accept(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, change := range accepted {
+		if err = s.authorise(r, importFaculties(change)...); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return set, accepted, nil
+}
+
+// compareImport compares the CSV file with the plan as it is now. Changes to
+// entries of faculties the user may not manage are rejected, except removals,
+// which are left out. Added entries are requested by the user making the
+// request, as they are when added any other way.
+func (s Server) compareImport(r *http.Request, csv string, removeMissing bool) (*importer.Diff, error) {
+	rows, err := importer.Parse(strings.NewReader(csv))
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := s.db.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := importer.Compare(rows, live, removeMissing, s.policy)
+	changes := diff.Changes[:0]
+
+	for _, change := range diff.Changes {
+		if change.Kind == sources.ChangeAdd {
+			setRequestor(r, change.After)
+		}
+
+		faculties := importFaculties(change)
+
+		if !s.canManage(r, faculties...) {
+			if change.Kind == sources.ChangeRemove {
+				continue
+			}
+
+			change.Errors = append(change.Errors,
+				fmt.Sprintf("You are not allowed to change entries of %s", strings.Join(faculties, " and ")))
+		}
+
+		changes = append(changes, change)
+	}
+
+	diff.Changes = changes
+
+	return diff, nil
+}
+
+// importFaculties returns the faculties of the entry before and after the
+// change.
+func importFaculties(change *importer.Change) []string {
+	var faculties []string
+
+	for _, entry := range []*sources.Entry{change.Before, change.After} {
+		if entry != nil && (len(faculties) == 0 || faculties[0] != entry.Faculty) {
+			faculties = append(faculties, entry.Faculty)
+		}
+	}
+
+	return faculties
+}
+
+// renderImportDiff compares the CSV file in the data with the plan and renders
+// the changes it would make. Files that cannot be read are described instead.
+func (s Server) renderImportDiff(w http.ResponseWriter, r *http.Request, data importDiffTmplData) {
+	diff, err := s.compareImport(r, data.CSV, data.RemoveMissing)
+	if err == nil {
+		data.Unchanged = diff.Unchanged
+
+		for _, change := range diff.Changes {
+			data.Changes = append(data.Changes, importChange{
+				Change: change,
+				Fields: diffEntries(change.Before, change.After),
+			})
+		}
+	} else if data.Message == "" {
+		data.Message = fmt.Sprintf("The file cannot be imported: %s", err)
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplImportDiffPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gocarina/gocsv"
+	. "github.com/smarty/assertions"
+)
+
+func TestImport(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	renamed := *originalEntries[0]
+	renamed.ReportingName = "renamed"
+
+	added := *originalEntries[0]
+	added.Directory = originalEntries[0].ReportingRoot + "/added"
+	added.ID = 0
+
+	invalid := *originalEntries[2]
+	invalid.Instruction = "keep"
+
+	csv := importCSV(t, &renamed, originalEntries[1], &invalid)
+
+	// The added entry has no ID, so it is matched by its directory.
+	csv += fmt.Sprintf("%s,%s,%s,%s,,,%s,%s,,,\n", added.ReportingName, added.ReportingRoot,
+		added.Directory, added.Instruction, added.Requestor, added.Faculty)
+
+	t.Run("The changes are shown before they are made", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PreviewImport(w, makeImportRequest(t, csv, true))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{
+			"3 changes, 1 rows already match the plan",
+			`value="update-0-0" checked`,
+			`value="update-2-0" disabled`,
+			"Input must be backup, nobackup or tempbackup",
+			`value="add-5"`, added.Directory,
+		} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("A file is needed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PreviewImport(w, makeImportRequest(t, "", false))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Changes with errors cannot be applied", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ApplyImport(w, makeApplyRequest(csv, "update-0-0", "update-2-0"))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "No changes were made: change has errors"); !ok {
+			t.Error(err)
+		}
+
+		stored, err := s.db.GetEntry(renamed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(stored, ShouldResemble, originalEntries[0]); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Accepted changes are applied and recorded", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ApplyImport(w, makeApplyRequest(csv, "update-0-0", "add-5"))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "Made 2 changes to the plan"); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldHaveLength, len(originalEntries)+1); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries[0].ReportingName, ShouldEqual, renamed.ReportingName); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(entries[len(entries)-1].Directory, ShouldEqual, added.Directory); !ok {
+			t.Error(err)
+		}
+
+		history, err := s.db.GetHistory(renamed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(history, ShouldHaveLength, 1); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(history[0].Operation, ShouldEqual, sources.OpUpdate); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Changes to entries changed since the preview are not applied", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ApplyImport(w, makeApplyRequest(csv, "update-0-0"))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "No changes were made: change is no longer in the diff"); !ok {
+			t.Error(err)
+		}
+	})
+}
+
+func TestImportAccessControl(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.access = &AccessPolicy{
+		Default: Grant{Role: RoleViewer},
+		Groups:  map[string]Grant{"editors": {Role: RoleEditor, Faculties: []string{"other"}}},
+	}
+
+	viewer := &User{Name: "victor"}
+	editor := &User{Name: "eve", Groups: []string{"editors"}}
+
+	renamed := *originalEntries[0]
+	renamed.ReportingName = "renamed"
+
+	csv := importCSV(t, &renamed)
+
+	t.Run("Viewers cannot import", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ShowImport(w, withUser(httptest.NewRequest(http.MethodGet, "/import", nil), viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.ApplyImport(w, withUser(makeApplyRequest(csv, "update-0-0"), viewer))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Editors cannot change or remove entries of other faculties", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PreviewImport(w, withUser(makeImportRequest(t, csv, true), editor))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"1 changes", "You are not allowed to change entries of group"} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+
+		w = httptest.NewRecorder()
+		s.ApplyImport(w, withUser(makeApplyRequest(csv, "update-0-0"), editor))
+
+		body = getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "No changes were made"); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Added entries are requested by the importer", func(t *testing.T) {
+		added := *originalEntries[0]
+		added.Directory += "/added"
+		added.Faculty = "other"
+		added.Requestor = "mallory"
+
+		withAdded := csv + fmt.Sprintf("%s,%s,%s,%s,,,%s,%s,,,\n", added.ReportingName, added.ReportingRoot,
+			added.Directory, added.Instruction, added.Requestor, added.Faculty)
+
+		w := httptest.NewRecorder()
+		s.ApplyImport(w, withUser(makeApplyRequest(withAdded, "add-3"), editor))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "Made 1 changes to the plan"); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldHaveLength, len(originalEntries)+1); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries[len(originalEntries)].Requestor, ShouldEqual, editor.Name); !ok {
+			t.Error(err)
+		}
+	})
+}
+
+// importCSV returns the entries as a CSV file of the kind the plan is exported
+// as.
+func importCSV(t *testing.T, entries ...*sources.Entry) string {
+	t.Helper()
+
+	csv, err := gocsv.MarshalString(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return csv
+}
+
+// makeImportRequest returns a multipart request uploading the CSV file, unless
+// it is empty.
+func makeImportRequest(t *testing.T, csv string, removeMissing bool) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	if removeMissing {
+		if err := mw.WriteField(removeMissingField, "true"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if csv != "" {
+		part, err := mw.CreateFormFile(importFileField, "plan.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = part.Write([]byte(csv)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/import/preview", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+// makeApplyRequest returns a request applying the changes the CSV file makes
+// with the given keys.
+func makeApplyRequest(csv string, keys ...string) *http.Request {
+	form := url.Values{importCSVField: {csv}, acceptedChangeField: keys}
+
+	r := httptest.NewRequest(http.MethodPost, "/import/apply", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return r
+}
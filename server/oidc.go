@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcLoginPath    = "/auth/login"
+	oidcCallbackPath = "/auth/callback"
+	oidcLogoutPath   = "/auth/logout"
+
+	sessionCookieName = "backup_plan_ui_session"
+	stateCookieName   = "backup_plan_ui_oidc_state"
+	stateLifetime     = 10 * time.Minute
+
+	DefaultSessionLifetime = 12 * time.Hour
+	DefaultUserClaim       = "preferred_username"
+	DefaultGroupsClaim     = "groups"
+
+	minSessionKeyLength = 32
+)
+
+var (
+	ErrInvalidCookie     = errors.New("invalid or expired cookie")
+	ErrInvalidState      = errors.New("login state does not match")
+	ErrMissingClaim      = errors.New("ID token is missing the user claim")
+	ErrSessionKeyTooWeak = fmt.Errorf("session key must be at least %d bytes", minSessionKeyLength)
+)
+
+// OIDCConfig configures an OIDCAuthenticator.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the absolute URL of the callback path on this server, as
+	// registered with the issuer.
+	RedirectURL string
+
+	// SessionKey signs the cookies holding the sessions of logged in users.
+	SessionKey []byte
+
+	// UserClaim and GroupsClaim are the ID token claims holding the user name
+	// and their groups. They default to DefaultUserClaim and DefaultGroupsClaim.
+	UserClaim   string
+	GroupsClaim string
+
+	// SessionLifetime defaults to DefaultSessionLifetime.
+	SessionLifetime time.Duration
+}
+
+// OIDCAuthenticator logs users in with an OpenID Connect issuer and keeps them
+// logged in with a signed session cookie. API clients can instead send an ID
+// token from the issuer as a bearer token.
+type OIDCAuthenticator struct {
+	config   OIDCConfig
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	cookies  cookieSigner
+	secure   bool
+}
+
+type oidcSession struct {
+	User    User
+	Expires time.Time
+}
+
+type oidcState struct {
+	State   string
+	Next    string
+	Expires time.Time
+}
+
+// NewOIDCAuthenticator discovers the configuration of the issuer and returns
+// an authenticator for it.
+func NewOIDCAuthenticator(ctx context.Context, config OIDCConfig) (*OIDCAuthenticator, error) {
+	if len(config.SessionKey) < minSessionKeyLength {
+		return nil, ErrSessionKeyTooWeak
+	}
+
+	if config.UserClaim == "" {
+		config.UserClaim = DefaultUserClaim
+	}
+
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+
+	if config.SessionLifetime == 0 {
+		config.SessionLifetime = DefaultSessionLifetime
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthenticator{
+		config: config,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "groups"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		cookies:  cookieSigner{key: config.SessionKey},
+		secure:   strings.HasPrefix(config.RedirectURL, "https://"),
+	}, nil
+}
+
+// Routes adds the login, callback and logout handlers to the router. They must
+// not require authentication.
+func (a *OIDCAuthenticator) Routes(r chi.Router) {
+	r.Get(oidcLoginPath, a.login)
+	r.Get(oidcCallbackPath, a.callback)
+	r.Get(oidcLogoutPath, a.logout)
+}
+
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*User, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.userFromToken(r.Context(), token)
+	}
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no session", ErrNotAuthenticated)
+	}
+
+	var session oidcSession
+
+	if err = a.cookies.decode(sessionCookieName, cookie.Value, &session); err != nil ||
+		time.Now().After(session.Expires) || session.User.Name == "" {
+		return nil, fmt.Errorf("%w: %w", ErrNotAuthenticated, ErrInvalidCookie)
+	}
+
+	return &session.User, nil
+}
+
+// Challenge sends users of the web interface to the login page, and tells API
+// clients they need a token.
+func (a *OIDCAuthenticator) Challenge(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+
+		return
+	}
+
+	loginURL := oidcLoginPath + "?" + url.Values{"next": {r.URL.RequestURI()}}.Encode()
+
+	if r.Header.Get("HX-Request") != "" {
+		// htmx requests fetch fragments, so the whole page is sent to log in
+		w.Header().Set("HX-Redirect", oidcLoginPath)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) login(w http.ResponseWriter, r *http.Request) {
+	state := oidcState{
+		State:   rand.Text(),
+		Next:    safeRedirect(r.URL.Query().Get("next")),
+		Expires: time.Now().Add(stateLifetime),
+	}
+
+	if err := a.setCookie(w, stateCookieName, state, stateLifetime); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	http.Redirect(w, r, a.oauth.AuthCodeURL(state.State), http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) callback(w http.ResponseWriter, r *http.Request) {
+	state, err := a.checkState(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	a.clearCookie(w, stateCookieName)
+
+	token, err := a.oauth.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		slog.Error("Failed to exchange OIDC code: " + err.Error())
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "Login failed: no ID token", http.StatusUnauthorized)
+
+		return
+	}
+
+	user, err := a.userFromToken(r.Context(), rawIDToken)
+	if err != nil {
+		slog.Error("Failed to verify OIDC ID token: " + err.Error())
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+
+		return
+	}
+
+	session := oidcSession{User: *user, Expires: time.Now().Add(a.config.SessionLifetime)}
+
+	if err = a.setCookie(w, sessionCookieName, session, a.config.SessionLifetime); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	slog.Info("User logged in: " + user.Name)
+
+	http.Redirect(w, r, state.Next, http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) checkState(r *http.Request) (*oidcState, error) {
+	cookie, err := r.Cookie(stateCookieName)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	var state oidcState
+
+	if err = a.cookies.decode(stateCookieName, cookie.Value, &state); err != nil || time.Now().After(state.Expires) {
+		return nil, ErrInvalidState
+	}
+
+	if !hmac.Equal([]byte(state.State), []byte(r.URL.Query().Get("state"))) {
+		return nil, ErrInvalidState
+	}
+
+	return &state, nil
+}
+
+func (a *OIDCAuthenticator) logout(w http.ResponseWriter, r *http.Request) {
+	a.clearCookie(w, sessionCookieName)
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (a *OIDCAuthenticator) userFromToken(ctx context.Context, rawIDToken string) (*User, error) {
+	idToken, err := a.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotAuthenticated, err)
+	}
+
+	var claims map[string]any
+
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	name, _ := claims[a.config.UserClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%w: %w %q", ErrNotAuthenticated, ErrMissingClaim, a.config.UserClaim)
+	}
+
+	user := &User{Name: name}
+
+	groups, _ := claims[a.config.GroupsClaim].([]any)
+	for _, group := range groups {
+		if g, ok := group.(string); ok {
+			user.Groups = append(user.Groups, g)
+		}
+	}
+
+	return user, nil
+}
+
+func (a *OIDCAuthenticator) setCookie(w http.ResponseWriter, name string, value any, lifetime time.Duration) error {
+	encoded, err := a.cookies.encode(name, value)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     "/",
+		MaxAge:   int(lifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (a *OIDCAuthenticator) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeRedirect returns the path to return to after logging in, only allowing
+// paths on this server.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+
+	return next
+}
+
+// cookieSigner encodes values as JSON signed with an HMAC, so they can be kept
+// in cookies without being altered. The signature covers the name of the
+// cookie, so the value of one cookie cannot be passed off as another.
+type cookieSigner struct {
+	key []byte
+}
+
+func (c cookieSigner) encode(name string, v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(name, encoded)), nil
+}
+
+func (c cookieSigner) decode(name, s string, v any) error {
+	encoded, signature, ok := strings.Cut(s, ".")
+	if !ok {
+		return ErrInvalidCookie
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(name, encoded)) {
+		return ErrInvalidCookie
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidCookie
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+func (c cookieSigner) sign(name, encoded string) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(name + "|" + encoded))
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-jose/go-jose/v4"
+	. "github.com/smarty/assertions"
+)
+
+const (
+	testClientID    = "backup-plan-ui"
+	testRedirectURL = "http://localhost:4000/auth/callback"
+)
+
+// stubIssuer is a minimal OpenID Connect issuer, handing out ID tokens with its
+// claims for any code.
+type stubIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &stubIssuer{key: key, claims: map[string]any{
+		"preferred_username": "alice",
+		"groups":             []string{"hgi"},
+	}}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeStubJSON(w, map[string]any{
+			"issuer":                                issuer.URL,
+			"authorization_endpoint":                issuer.URL + "/authorize",
+			"token_endpoint":                        issuer.URL + "/token",
+			"jwks_uri":                              issuer.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		writeStubJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, _ *http.Request) {
+		writeStubJSON(w, map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     issuer.idToken(t),
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+
+	return issuer
+}
+
+func (s *stubIssuer) idToken(t *testing.T) string {
+	t.Helper()
+
+	claims := map[string]any{
+		"iss": s.URL,
+		"aud": testClientID,
+		"sub": "1234",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256,
+		Key: jose.JSONWebKey{Key: s.key, KeyID: "test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func writeStubJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func createOIDCAuthenticator(t *testing.T, issuer *stubIssuer) *OIDCAuthenticator {
+	t.Helper()
+
+	auth, err := NewOIDCAuthenticator(context.Background(), OIDCConfig{
+		IssuerURL:    issuer.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+		SessionKey:   []byte(strings.Repeat("k", minSessionKeyLength)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return auth
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	issuer := newStubIssuer(t)
+	auth := createOIDCAuthenticator(t, issuer)
+
+	router := chi.NewRouter()
+	auth.Routes(router)
+
+	expectedUser := &User{Name: "alice", Groups: []string{"hgi"}}
+
+	var sessionCookie *http.Cookie
+
+	t.Run("Users log in with the issuer and get a session", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, oidcLoginPath+"?next=/entries", nil))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusFound); !ok {
+			t.Fatal(err)
+		}
+
+		authorizeURL, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(authorizeURL.String(), ShouldStartWith, issuer.URL+"/authorize"); !ok {
+			t.Error(err)
+		}
+
+		callback := oidcCallbackPath + "?" + url.Values{
+			"code":  {"code"},
+			"state": {authorizeURL.Query().Get("state")},
+		}.Encode()
+
+		r := httptest.NewRequest(http.MethodGet, callback, nil)
+		for _, cookie := range w.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusFound); !ok {
+			t.Fatal(err, w.Body.String())
+		}
+
+		if ok, err := So(w.Header().Get("Location"), ShouldEqual, "/entries"); !ok {
+			t.Error(err)
+		}
+
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == sessionCookieName {
+				sessionCookie = cookie
+			}
+		}
+
+		if sessionCookie == nil {
+			t.Fatal("no session cookie was set")
+		}
+
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(sessionCookie)
+
+		user, err := auth.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(user, ShouldResemble, expectedUser); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Tampered sessions are rejected", func(t *testing.T) {
+		if sessionCookie == nil {
+			t.Skip("no session to tamper with")
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "x" + sessionCookie.Value})
+
+		_, err := auth.Authenticate(r)
+		if !errors.Is(err, ErrNotAuthenticated) {
+			t.Errorf("expected ErrNotAuthenticated, got %v", err)
+		}
+	})
+
+	t.Run("Login state cannot be used as a session", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, oidcLoginPath, nil))
+
+		var stateCookie *http.Cookie
+
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == stateCookieName {
+				stateCookie = cookie
+			}
+		}
+
+		if stateCookie == nil {
+			t.Fatal("no state cookie was set")
+		}
+
+		s := Server{auth: auth}
+		handler := s.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/entries", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: stateCookie.Value})
+
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusUnauthorized); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Sessions without a user are rejected", func(t *testing.T) {
+		encoded, err := auth.cookies.encode(sessionCookieName, oidcSession{Expires: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: encoded})
+
+		if _, err = auth.Authenticate(r); !errors.Is(err, ErrNotAuthenticated) {
+			t.Errorf("expected ErrNotAuthenticated, got %v", err)
+		}
+	})
+
+	t.Run("Callbacks with the wrong state are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?code=code&state=forged", nil))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("API clients can use an ID token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/entries", nil)
+		r.Header.Set("Authorization", "Bearer "+issuer.idToken(t))
+
+		user, err := auth.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(user, ShouldResemble, expectedUser); !ok {
+			t.Error(err)
+		}
+	})
+}
+
+func TestOIDCAuthenticatorChallenge(t *testing.T) {
+	auth := createOIDCAuthenticator(t, newStubIssuer(t))
+
+	tests := []struct {
+		name           string
+		path           string
+		htmx           bool
+		expectedStatus int
+		expectedHeader string
+		expectedValue  string
+	}{
+		{"Pages redirect to the login", "/?a=b", false, http.StatusFound, "Location",
+			oidcLoginPath + "?next=%2F%3Fa%3Db"},
+		{"htmx requests redirect the whole page", "/entries", true, http.StatusUnauthorized, "HX-Redirect",
+			oidcLoginPath},
+		{"API requests ask for a token", "/api/v1/entries", false, http.StatusUnauthorized, "WWW-Authenticate",
+			"Bearer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.htmx {
+				r.Header.Set("HX-Request", "true")
+			}
+
+			w := httptest.NewRecorder()
+			auth.Challenge(w, r)
+
+			if ok, err := So(w.Code, ShouldEqual, tt.expectedStatus); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(w.Header().Get(tt.expectedHeader), ShouldEqual, tt.expectedValue); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	for next, expected := range map[string]string{
+		"/entries":           "/entries",
+		"":                   "/",
+		"https://evil.com/":  "/",
+		"//evil.com/":        "/",
+		"/\\evil.com/":       "/",
+		"/api/v1/entries?a=": "/api/v1/entries?a=",
+	} {
+		if ok, err := So(safeRedirect(next), ShouldEqual, expected); !ok {
+			t.Error(err)
+		}
+	}
+}
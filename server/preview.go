@@ -0,0 +1,147 @@
+package server
+
+import (
+	"backup-plan-ui/resolver"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	tmplPreviewPath = "preview_modal.html"
+	listingField    = "Listing"
+
+	// maxListingSize is the largest file listing that can be uploaded.
+	maxListingSize = 64 << 20
+
+	// maxListingMemory is how much of an uploaded listing is kept in memory,
+	// the rest going to a temporary file.
+	maxListingMemory = 8 << 20
+
+	maxPreviewExamples = 10
+)
+
+var errNoListing = errors.New("choose a file listing to preview against")
+
+type previewTmplData struct {
+	*resolver.Preview
+	Source string
+	Errors []string
+}
+
+// PreviewPatterns opens a dialog showing how many, and which, of the paths in a
+// file listing the entry being edited or added would back up, given its
+// Directory, Instruction, Match and Ignore. The listing, one path per line, is
+// uploaded with the form, or else is the one configured for the server. Only
+// users who may manage the faculty of the entry can preview it.
+func (s Server) PreviewPatterns(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageAny(r) {
+		s.abortWithError(w, ErrForbidden, http.StatusForbidden)
+
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxListingSize)
+
+	if err := r.ParseMultipartForm(maxListingMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	entry := createEntryFromForm(0, r)
+
+	if err := s.authorise(r, entry.Faculty); err != nil {
+		s.abortWithError(w, err, http.StatusForbidden)
+
+		return
+	}
+	data := previewTmplData{Preview: resolver.NewPreview(entry, maxPreviewExamples), Errors: previewErrors(r)}
+
+	if len(data.Errors) == 0 {
+		listing, source, err := s.openListing(r)
+		if errors.Is(err, errNoListing) {
+			s.abortWithError(w, err, http.StatusBadRequest)
+
+			return
+		} else if err != nil {
+			s.abortWithError(w, err, http.StatusInternalServerError)
+
+			return
+		}
+
+		defer listing.Close()
+
+		data.Source = source
+
+		if err = addListing(data.Preview, listing); err != nil {
+			s.abortWithError(w, err, http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	if err := s.templates.ExecuteTemplate(w, tmplPreviewPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// previewErrors returns the problems with the form that would make a preview
+// meaningless.
+func previewErrors(r *http.Request) []string {
+	var errs []string
+
+	if dir := r.FormValue(Directory.string()); !path.IsAbs(dir) {
+		errs = append(errs, fmt.Sprintf("The directory %q is not an absolute path", dir))
+	}
+
+	for _, field := range []formField{Match, Ignore} {
+		for _, err := range resolver.CheckPatterns(resolver.SplitPatterns(r.FormValue(field.string()))) {
+			errs = append(errs, fmt.Sprintf("%s pattern %s", field, err))
+		}
+	}
+
+	return errs
+}
+
+// openListing returns the file listing uploaded with the request, or the
+// configured one, along with a name for it.
+func (s Server) openListing(r *http.Request) (io.ReadCloser, string, error) {
+	file, header, err := r.FormFile(listingField)
+	if err == nil {
+		return file, header.Filename, nil
+	}
+
+	if !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, "", err
+	}
+
+	if s.listing == "" {
+		return nil, "", errNoListing
+	}
+
+	listing, err := os.Open(s.listing)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return listing, s.listing, nil
+}
+
+// addListing adds each non-blank line of the listing to the preview.
+func addListing(preview *resolver.Preview, listing io.Reader) error {
+	scanner := bufio.NewScanner(listing)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			preview.Add(line)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestPreviewPatterns(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	entry := *originalEntries[0]
+	entry.Instruction = sources.Backup
+	entry.Match = "*.cram"
+	entry.Ignore = ""
+
+	listing := "x.cram\n\nsub/y.bam\n/elsewhere/z.cram\n"
+
+	t.Run("An uploaded listing is previewed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PreviewPatterns(w, makePreviewRequest(t, createFormFromEntry(entry), listing))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"<strong>1</strong> would be backed up", "<strong>1</strong> would not",
+			"1 paths outside", entry.Directory + "/x.cram", entry.Directory + "/sub/y.bam"} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("A listing is needed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PreviewPatterns(w, makePreviewRequest(t, createFormFromEntry(entry), ""))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("The configured listing is used when none is uploaded", func(t *testing.T) {
+		s := s
+		s.listing = filepath.Join(t.TempDir(), "listing.txt")
+
+		if err := os.WriteFile(s.listing, []byte(listing), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		s.PreviewPatterns(w, makePreviewRequest(t, createFormFromEntry(entry), ""))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "<strong>1</strong> would be backed up"); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Invalid directories and patterns are reported", func(t *testing.T) {
+		invalid := entry
+		invalid.Directory = "relative"
+		invalid.Match = "[x"
+
+		w := httptest.NewRecorder()
+		s.PreviewPatterns(w, makePreviewRequest(t, createFormFromEntry(invalid), listing))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"is not an absolute path", "Match pattern"} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("Only users who may manage the faculty can preview", func(t *testing.T) {
+		s := s
+		s.access = &AccessPolicy{
+			Default: Grant{Role: RoleViewer},
+			Groups:  map[string]Grant{"editors": {Role: RoleEditor, Faculties: []string{"other"}}},
+		}
+
+		for _, user := range []*User{{Name: "victor"}, {Name: "eve", Groups: []string{"editors"}}} {
+			w := httptest.NewRecorder()
+			s.PreviewPatterns(w, withUser(makePreviewRequest(t, createFormFromEntry(entry), listing), user))
+
+			if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+				t.Errorf("%s: %s", user.Name, err)
+			}
+		}
+
+		s.access.Groups["editors"] = Grant{Role: RoleEditor, Faculties: []string{entry.Faculty}}
+
+		w := httptest.NewRecorder()
+		s.PreviewPatterns(w, withUser(makePreviewRequest(t, createFormFromEntry(entry), listing),
+			&User{Name: "eve", Groups: []string{"editors"}}))
+
+		getBodyAndCheckStatusOK(t, w)
+	})
+}
+
+// makePreviewRequest returns a multipart request with the given form, and the
+// listing as an uploaded file unless it is empty.
+func makePreviewRequest(t *testing.T, form url.Values, listing string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	for key, values := range form {
+		for _, value := range values {
+			if err := mw.WriteField(key, value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if listing != "" {
+		part, err := mw.CreateFormFile(listingField, "listing.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = part.Write([]byte(listing)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/actions/preview", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	tmplProposalsPath     = "proposals.html"
+	tmplProposalRowPath   = "proposal_row.html"
+	tmplProposalToastPath = "proposal_toast.html"
+
+	// proposalToastWindow is how long the toast saying a change was sent for
+	// review is shown.
+	proposalToastWindow = 10 * time.Second
+)
+
+var ErrNotReviewer = errors.New("only admins may review proposed changes")
+
+// proposalView is a proposal as shown for review.
+type proposalView struct {
+	*sources.Proposal
+	Changes []fieldChange
+
+	// Stale is true if the entry has changed since the proposal was made, so it
+	// can no longer be approved.
+	Stale     bool
+	CanReview bool
+	Error     string
+}
+
+type proposalsTmplData struct {
+	User      *User
+	CSRFToken string
+	Proposals []proposalView
+}
+
+type proposalToastTmplData struct {
+	*sources.Proposal
+	ToastMillis int64
+}
+
+// propose stores a change for an admin to review instead of making it.
+func (s Server) propose(r *http.Request, op sources.Operation, before,
+	after *sources.Entry) (*sources.Proposal, error) {
+	proposal := sources.NewProposal(op, getActor(r), before, after)
+
+	if err := s.db.AddProposal(proposal); err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Proposed %s of entry: %+v\n", op, *proposal.Entry()))
+
+	return proposal, nil
+}
+
+// showProposed tells the user their change was sent for review.
+func (s Server) showProposed(w http.ResponseWriter, proposal *sources.Proposal) {
+	data := proposalToastTmplData{Proposal: proposal, ToastMillis: proposalToastWindow.Milliseconds()}
+
+	if err := s.templates.ExecuteTemplate(w, tmplProposalToastPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// ShowProposals renders a page listing the changes waiting for review, oldest
+// first, with their differences from the entries they change. Admins can
+// approve or reject them.
+func (s Server) ShowProposals(w http.ResponseWriter, r *http.Request) {
+	proposals, err := s.db.ListProposals(sources.ProposalPending)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	live, err := s.entriesByID()
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	data := proposalsTmplData{User: getUser(r), CSRFToken: getCSRFToken(r)}
+
+	for _, proposal := range proposals {
+		data.Proposals = append(data.Proposals, s.proposalView(r, proposal, live))
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplProposalsPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// proposalView describes the proposal for the user making the request, given
+// the live entries by ID.
+func (s Server) proposalView(r *http.Request, proposal *sources.Proposal,
+	live map[uint64]*sources.Entry) proposalView {
+	view := proposalView{
+		Proposal:  proposal,
+		Changes:   diffEntries(proposal.Before, proposal.After),
+		CanReview: s.isAdmin(r),
+	}
+
+	if before := proposal.Before; before != nil {
+		current, ok := live[before.ID]
+		view.Stale = !ok || current.Version != before.Version
+	} else if proposal.Operation == sources.OpRestore {
+		_, view.Stale = live[proposal.After.ID]
+	}
+
+	return view
+}
+
+// ApproveProposal makes a proposed change as if the admin approving it had made
+// it, and records them as its reviewer. The change is refused if the entry has
+// changed since it was proposed.
+func (s Server) ApproveProposal(w http.ResponseWriter, r *http.Request) {
+	s.reviewProposalRow(w, r, sources.ProposalApproved)
+}
+
+// RejectProposal records that an admin rejected a proposed change, which is not
+// made.
+func (s Server) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	s.reviewProposalRow(w, r, sources.ProposalRejected)
+}
+
+// reviewProposalRow reviews the proposal with the ID in the URL, and renders its
+// row again with the decision, or with the reason it could not be made.
+func (s Server) reviewProposalRow(w http.ResponseWriter, r *http.Request, status sources.ProposalStatus) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	proposal, reviewErr := s.reviewProposal(r, id, status)
+	if reviewErr != nil && !errors.Is(reviewErr, sources.ErrVersionConflict) &&
+		!errors.Is(reviewErr, sources.ErrNoEntry) && !errors.Is(reviewErr, sources.ErrNotDeleted) {
+		s.abortWithError(w, reviewErr, statusForError(reviewErr))
+
+		return
+	}
+
+	view := proposalView{}
+
+	if reviewErr != nil {
+		// the entry changed since the proposal was made, so leave it pending and
+		// say why it could not be approved
+		if proposal, err = s.db.GetProposal(id); err != nil {
+			s.abortWithError(w, err, statusForError(err))
+
+			return
+		}
+
+		view = proposalView{CanReview: true, Stale: true, Error: reviewErr.Error()}
+	}
+
+	view.Proposal = proposal
+	view.Changes = diffEntries(proposal.Before, proposal.After)
+
+	if err = s.templates.ExecuteTemplate(w, tmplProposalRowPath, view); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// reviewProposal approves or rejects the pending proposal with the given ID,
+// recording the user making the request as its reviewer. The proposal is
+// claimed before an approved change is made, so that only one reviewer can make
+// it, and reopened if the change cannot be made through the same checks as
+// changes made directly.
+func (s Server) reviewProposal(r *http.Request, id uint64, status sources.ProposalStatus) (*sources.Proposal, error) {
+	if !s.isAdmin(r) {
+		return nil, ErrNotReviewer
+	}
+
+	proposal, err := s.db.ReviewProposal(id, status, getActor(r))
+	if err != nil {
+		return nil, err
+	}
+
+	if status == sources.ProposalApproved {
+		if err = s.applyProposal(r, proposal); err != nil && !errors.Is(err, errHistoryNotRecorded) {
+			if reopenErr := s.db.ReopenProposal(id); reopenErr != nil {
+				slog.Error(fmt.Sprintf("Failed to reopen proposal %d: %s", id, reopenErr))
+			}
+
+			return nil, err
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Proposal %d %s by %s", proposal.ID, proposal.Status, proposal.Reviewer))
+
+	return proposal, nil
+}
+
+// applyProposal makes the proposed change.
+func (s Server) applyProposal(r *http.Request, proposal *sources.Proposal) error {
+	var err error
+
+	switch proposal.Operation {
+	case sources.OpAdd:
+		entry := *proposal.After
+		_, err = s.addEntry(r, &entry)
+	case sources.OpUpdate:
+		entry := *proposal.After
+		_, err = s.updateEntry(r, &entry)
+	case sources.OpDelete:
+		_, _, err = s.deleteEntry(r, proposal.Before.ID, proposal.Before.Version)
+	case sources.OpRestore:
+		_, _, err = s.restoreEntry(r, proposal.After.ID)
+	default:
+		err = fmt.Errorf("cannot apply a proposed %s", proposal.Operation)
+	}
+
+	return err
+}
+
+// APIListProposals responds with the changes waiting for review, oldest first.
+func (s Server) APIListProposals(w http.ResponseWriter, _ *http.Request) {
+	proposals, err := s.db.ListProposals(sources.ProposalPending)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if proposals == nil {
+		proposals = []*sources.Proposal{}
+	}
+
+	s.writeJSON(w, http.StatusOK, proposals)
+}
+
+// APIApproveProposal approves a pending proposal, making its change, and
+// responds with the reviewed proposal.
+func (s Server) APIApproveProposal(w http.ResponseWriter, r *http.Request) {
+	s.apiReviewProposal(w, r, sources.ProposalApproved)
+}
+
+// APIRejectProposal rejects a pending proposal and responds with the reviewed
+// proposal.
+func (s Server) APIRejectProposal(w http.ResponseWriter, r *http.Request) {
+	s.apiReviewProposal(w, r, sources.ProposalRejected)
+}
+
+func (s Server) apiReviewProposal(w http.ResponseWriter, r *http.Request, status sources.ProposalStatus) {
+	id, err := getIDFromURL(r)
+	if err != nil {
+		s.writeJSONError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	proposal, err := s.reviewProposal(r, id, status)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, proposal)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestProposals(t *testing.T) {
+	s, originalEntries := createServer(t)
+	s.access = &AccessPolicy{
+		Default:         Grant{Role: RoleEditor, Faculties: []string{"group"}},
+		Groups:          map[string]Grant{"admins": {Role: RoleAdmin}},
+		RequireApproval: true,
+	}
+
+	editor := &User{Name: "eve"}
+	admin := &User{Name: "ada", Groups: []string{"admins"}}
+
+	renamed := *originalEntries[0]
+	renamed.ReportingName = "renamed"
+
+	t.Run("Changes by editors are proposed instead of made", func(t *testing.T) {
+		r := makeFormRequest(createFormFromEntry(renamed), "/actions/submit", fmt.Sprint(renamed.ID))
+
+		w := httptest.NewRecorder()
+		s.SubmitEdits(w, withUser(r, editor))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"sent to an admin for review", originalEntries[0].ReportingName} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+
+		w = httptest.NewRecorder()
+		s.DeleteRow(w, withUser(makeVersionedRequest(originalEntries[1].ID, originalEntries[1].Version), editor))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "sent to an admin for review"); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+			t.Error(err)
+		}
+
+		proposals, err := s.db.ListProposals(sources.ProposalPending)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(proposals, ShouldHaveLength, 2); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(proposals[0].Proposer, ShouldEqual, editor.Name); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(proposals[0].After, ShouldResemble, &renamed); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("The API accepts proposed changes without making them", func(t *testing.T) {
+		added := *originalEntries[2]
+		added.Directory += "/added"
+
+		w := httptest.NewRecorder()
+		s.APIAddEntry(w, withUser(makeJSONRequest(http.MethodPost, "", mustMarshal(t, added)), editor))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusAccepted); !ok {
+			t.Fatal(err)
+		}
+
+		var proposal sources.Proposal
+
+		if err := json.Unmarshal(w.Body.Bytes(), &proposal); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(proposal.Status, ShouldEqual, sources.ProposalPending); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.APIRejectProposal(w, withUser(makeRequest(proposal.ID), admin))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusOK); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldHaveLength, len(originalEntries)); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Everyone sees the review queue, but only admins can review", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ShowProposals(w, withUser(httptest.NewRequest(http.MethodGet, "/proposals", nil), editor))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"2 changes are waiting", "renamed", "Waiting for review"} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+
+		if ok, err := So(body, ShouldNotContainSubstring, "Approve"); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.ApproveProposal(w, withUser(makeRequest(1), editor))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Approved changes are made and recorded with their reviewer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ApproveProposal(w, withUser(makeRequest(1), admin))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "approved by ada"); !ok {
+			t.Error(err)
+		}
+
+		stored, err := s.db.GetEntry(renamed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(stored.ReportingName, ShouldEqual, renamed.ReportingName); !ok {
+			t.Error(err)
+		}
+
+		history, err := s.db.GetHistory(renamed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(history, ShouldHaveLength, 1); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(history[0].Actor, ShouldEqual, admin.Name); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.ApproveProposal(w, withUser(makeRequest(1), admin))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusConflict); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Rejected changes are not made", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.RejectProposal(w, withUser(makeRequest(2), admin))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "rejected by ada"); !ok {
+			t.Error(err)
+		}
+
+		if _, err := s.db.GetEntry(originalEntries[1].ID); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Changes to entries changed since they were proposed cannot be approved", func(t *testing.T) {
+		stale := renamed
+		stale.ReportingName = "stale"
+
+		proposal := sources.NewProposal(sources.OpUpdate, editor.Name, originalEntries[0], &stale)
+		if err := s.db.AddProposal(proposal); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		s.ApproveProposal(w, withUser(makeRequest(proposal.ID), admin))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "The entry has changed since this was proposed"); !ok {
+			t.Error(err)
+		}
+
+		stored, err := s.db.GetProposal(proposal.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(stored.Status, ShouldEqual, sources.ProposalPending); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Only the first approval of a proposal makes its change", func(t *testing.T) {
+		added := *originalEntries[2]
+		added.Directory += "/approved"
+
+		proposal := sources.NewProposal(sources.OpAdd, editor.Name, nil, &added)
+		if err := s.db.AddProposal(proposal); err != nil {
+			t.Fatal(err)
+		}
+
+		codes := make(chan int, 2)
+
+		var wg sync.WaitGroup
+
+		for range 2 {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				w := httptest.NewRecorder()
+				s.APIApproveProposal(w, withUser(makeRequest(proposal.ID), admin))
+				codes <- w.Code
+			}()
+		}
+
+		wg.Wait()
+		close(codes)
+
+		var received []int
+		for code := range codes {
+			received = append(received, code)
+		}
+
+		if ok, err := So(received, ShouldContain, http.StatusOK); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(received, ShouldContain, http.StatusConflict); !ok {
+			t.Error(err)
+		}
+
+		entries, err := s.db.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldHaveLength, len(originalEntries)+1); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Restores by editors are proposed instead of made", func(t *testing.T) {
+		deleted := originalEntries[1]
+
+		if _, err := s.db.DeleteEntry(deleted.ID, deleted.Version); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		s.RestoreRow(w, withUser(makeRequest(deleted.ID), editor))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "sent to an admin for review"); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.APIRestoreEntry(w, withUser(makeRequest(deleted.ID), editor))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusAccepted); !ok {
+			t.Fatal(err)
+		}
+
+		if _, err := s.db.GetEntry(deleted.ID); !errors.Is(err, sources.ErrNoEntry) {
+			t.Errorf("expected %v, got %v", sources.ErrNoEntry, err)
+		}
+
+		var proposal sources.Proposal
+
+		if err := json.Unmarshal(w.Body.Bytes(), &proposal); err != nil {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.APIApproveProposal(w, withUser(makeRequest(proposal.ID), admin))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusOK); !ok {
+			t.Error(err)
+		}
+
+		if _, err := s.db.GetEntry(deleted.ID); err != nil {
+			t.Error(err)
+		}
+	})
+}
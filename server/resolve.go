@@ -0,0 +1,64 @@
+package server
+
+import (
+	"backup-plan-ui/resolver"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	tmplResolvePath = "resolve_result.html"
+	queryPath       = "path"
+)
+
+var errMissingPath = errors.New("missing path to look up")
+
+// resolve works out what the plan does with the file at the path given in the
+// request's query.
+func (s Server) resolve(r *http.Request) (*resolver.Resolution, error) {
+	p := r.URL.Query().Get(queryPath)
+	if p == "" {
+		return nil, fmt.Errorf("%w: %w", errInvalidQuery, errMissingPath)
+	}
+
+	entries, err := s.db.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resolver.Resolve(entries, p)
+	if errors.Is(err, resolver.ErrRelativePath) {
+		return nil, fmt.Errorf("%w: %w", errInvalidQuery, err)
+	}
+
+	return res, err
+}
+
+// ResolvePath shows which entry governs the path given in the query, and whether
+// it is backed up.
+func (s Server) ResolvePath(w http.ResponseWriter, r *http.Request) {
+	res, err := s.resolve(r)
+	if err != nil {
+		s.abortWithError(w, err, statusForError(err))
+
+		return
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplResolvePath, res); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// APIResolvePath responds with the entry governing the path given in the query,
+// the patterns applied to it and whether it is backed up.
+func (s Server) APIResolvePath(w http.ResponseWriter, r *http.Request) {
+	res, err := s.resolve(r)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, res)
+}
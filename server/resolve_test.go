@@ -0,0 +1,71 @@
+package server
+
+import (
+	"backup-plan-ui/resolver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestResolvePath(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	path := originalEntries[0].Directory + "/file.txt"
+
+	w := httptest.NewRecorder()
+	s.ResolvePath(w, httptest.NewRequest(http.MethodGet, "/actions/resolve?path="+url.QueryEscape(path), nil))
+
+	body := getBodyAndCheckStatusOK(t, w)
+
+	if ok, err := So(body, ShouldContainSubstring, originalEntries[0].ReportingName); !ok {
+		t.Error(err)
+	}
+
+	w = httptest.NewRecorder()
+	s.ResolvePath(w, httptest.NewRequest(http.MethodGet, "/actions/resolve?path=relative/file.txt", nil))
+
+	if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+		t.Error(err)
+	}
+}
+
+func TestAPIResolvePath(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	testCases := []struct {
+		name     string
+		query    string
+		status   int
+		decision resolver.Decision
+	}{
+		{"Paths in an entry are resolved", "?path=" + url.QueryEscape(originalEntries[0].Directory+"/a/b"),
+			http.StatusOK, resolver.Backup},
+		{"Paths outside every entry are unplanned", "?path=/elsewhere", http.StatusOK, resolver.Unplanned},
+		{"A path must be given", "", http.StatusBadRequest, ""},
+		{"The path must be absolute", "?path=elsewhere", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.APIResolvePath(w, httptest.NewRequest(http.MethodGet, "/api/v1/resolve"+tt.query, nil))
+
+			if tt.status != http.StatusOK {
+				var apiErr apiError
+				decodeJSONResponse(t, w, tt.status, &apiErr)
+
+				return
+			}
+
+			var res resolver.Resolution
+			decodeJSONResponse(t, w, http.StatusOK, &res)
+
+			if ok, err := So(res.Decision, ShouldEqual, tt.decision); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
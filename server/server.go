@@ -1,8 +1,11 @@
 package server
 
 import (
+	"backup-plan-ui/health"
 	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
@@ -11,6 +14,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -18,6 +22,33 @@ import (
 type Server struct {
 	db        sources.DataSource
 	templates *template.Template
+	auth      Authenticator
+	access    *AccessPolicy
+	retention time.Duration
+	listing   string
+	policy    *validation.Policy
+	changes   *changeHub
+}
+
+// Config holds the optional parts of a Server.
+type Config struct {
+	// Auth identifies users, who are anonymous without it.
+	Auth Authenticator
+
+	// Access decides which entries users may change. Without it anyone may
+	// change any entry.
+	Access *AccessPolicy
+
+	// DeletedRetention is how long deleted entries can be restored before
+	// PurgeDeletedEntries removes them. They are kept forever without it.
+	DeletedRetention time.Duration
+
+	// Listing is a file listing paths, one per line, to preview the Match and
+	// Ignore patterns of entries against when no listing is uploaded.
+	Listing string
+
+	// Validation is the policy entries must follow, the default one without it.
+	Validation *validation.Policy
 }
 
 const (
@@ -25,7 +56,8 @@ const (
 	maxPathCharacters = 50
 )
 
-func NewServer(db sources.DataSource, fs embed.FS) (*Server, error) {
+// NewServer returns a server for the plan in db.
+func NewServer(db sources.DataSource, fs embed.FS, config Config) (*Server, error) {
 	funcMap := template.FuncMap{
 		"ShortenPath":  ShortenPath,
 		"RemovePrefix": RemovePrefix,
@@ -38,6 +70,12 @@ func NewServer(db sources.DataSource, fs embed.FS) (*Server, error) {
 	return &Server{
 		db:        db,
 		templates: t,
+		auth:      config.Auth,
+		access:    config.Access,
+		retention: config.DeletedRetention,
+		listing:   config.Listing,
+		policy:    config.Validation,
+		changes:   newChangeHub(),
 	}, err
 }
 
@@ -56,6 +94,7 @@ const (
 	Ignore        formField = "Ignore"
 	Requestor     formField = "Requestor"
 	Faculty       formField = "Faculty"
+	Version       formField = "Version"
 
 	tmplRowPath          = "row.html"
 	tmplEditRowPath      = "edit_row.html"
@@ -65,12 +104,46 @@ const (
 )
 
 type tmplData struct {
-	Entry  *sources.Entry
-	Errors map[string]string
+	Entry    *sources.Entry
+	Errors   map[string]string
+	User     *User
+	CanEdit  bool
+	Warnings []health.Issue
+
+	// Proposed is true if the user's change to the entry is waiting for review.
+	Proposed bool
+}
+
+type indexTmplData struct {
+	User       *User
+	LogoutPath string
+	CanAdd     bool
+	CSRFToken  string
+
+	// ReviewsChanges is true if changes must be approved before they are made.
+	ReviewsChanges bool
+}
+
+// rowData returns the data to render the entry with for the user making the
+// request.
+func (s Server) rowData(r *http.Request, entry *sources.Entry) tmplData {
+	return tmplData{Entry: entry, CanEdit: s.canManage(r, entry.Faculty)}
 }
 
-func (s Server) ServeHome(w http.ResponseWriter, _ *http.Request) {
-	if err := s.templates.ExecuteTemplate(w, tmplIndexPath, nil); err != nil {
+func (s Server) ServeHome(w http.ResponseWriter, r *http.Request) {
+	data := indexTmplData{
+		User:      getUser(r),
+		CanAdd:    s.canManageAny(r),
+		CSRFToken: getCSRFToken(r),
+
+		ReviewsChanges: s.access != nil && s.access.RequireApproval,
+	}
+
+	if _, ok := s.auth.(*OIDCAuthenticator); ok {
+		data.LogoutPath = oidcLogoutPath
+	}
+
+	if err := s.templates.ExecuteTemplate(w, tmplIndexPath, data); err != nil {
 		s.abortWithError(w, err, http.StatusInternalServerError)
 	}
 }
@@ -80,42 +153,74 @@ func (s Server) abortWithError(w http.ResponseWriter, err error, statusCode int)
 	http.Error(w, err.Error(), statusCode)
 }
 
-func (s Server) GetEntries(w http.ResponseWriter, _ *http.Request) {
-	entries, err := s.db.ReadAll()
+// GetEntries renders a page of the entries selected by the request's query as
+// table rows, followed by a row that loads the next page when scrolled into view.
+func (s Server) GetEntries(w http.ResponseWriter, r *http.Request) {
+	query, err := queryFromRequest(r, entriesPageSize)
 	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	page, err := s.db.Query(query)
+	if isQueryError(err) {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	} else if err != nil {
 		s.abortWithError(w, err, http.StatusInternalServerError)
 
 		return
 	}
 
-	for _, entry := range entries {
-		err = s.templates.ExecuteTemplate(w, tmplRowPath, tmplData{Entry: entry})
+	shown := query.Offset + len(page.Entries)
+
+	w.Header().Set("HX-Trigger", fmt.Sprintf(`{"entriesCounted": {"shown": %d, "total": %d}}`, shown, page.Total))
+
+	for _, entry := range page.Entries {
+		err = s.templates.ExecuteTemplate(w, tmplRowPath, s.rowData(r, entry))
 		if err != nil {
 			s.abortWithError(w, err, http.StatusInternalServerError)
 		}
 	}
+
+	if shown >= page.Total || len(page.Entries) == 0 {
+		return
+	}
+
+	err = s.templates.ExecuteTemplate(w, tmplMoreEntriesPath, struct{ NextURL string }{
+		NextURL: nextPageURL(r, query, len(page.Entries)),
+	})
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
 }
 
 func (s Server) AllowUserToEditRow(w http.ResponseWriter, r *http.Request) {
 	err := s.changeTemplate(w, r, tmplEditRowPath)
 	if err != nil {
-		s.abortWithError(w, err, http.StatusBadRequest)
+		s.abortWithError(w, err, statusOrForbidden(err, http.StatusBadRequest))
 	}
 }
 
 func (s Server) changeTemplate(w http.ResponseWriter, r *http.Request, tmplPath string) error {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+	id, err := getIDFromURL(r)
 	if err != nil {
 		return err
 	}
 
-	entry, err := s.db.GetEntry(uint16(id))
+	entry, err := s.db.GetEntry(id)
 	if err != nil {
 		return err
 	}
 
-	return s.templates.ExecuteTemplate(w, tmplPath, tmplData{Entry: entry})
+	data := s.rowData(r, entry)
+	if tmplPath != tmplRowPath && !data.CanEdit {
+		return s.authorise(r, entry.Faculty)
+	}
+
+	return s.templates.ExecuteTemplate(w, tmplPath, data)
 }
 
 func (s Server) ResetView(w http.ResponseWriter, r *http.Request) {
@@ -132,8 +237,7 @@ func (s Server) ResetView(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s Server) SubmitEdits(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+	id, err := getIDFromURL(r)
 	if err != nil {
 		s.abortWithError(w, err, http.StatusBadRequest)
 
@@ -147,8 +251,16 @@ func (s Server) SubmitEdits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validationErrors := validateForm(r)
-	updatedEntry := createEntryFromForm(uint16(id), r)
+	version, err := getVersion(r)
+	if err != nil {
+		s.abortWithError(w, err, http.StatusBadRequest)
+
+		return
+	}
+
+	validationErrors := s.validateForm(r)
+	updatedEntry := createEntryFromForm(id, r)
+	updatedEntry.Version = version
 
 	if len(validationErrors) > 0 {
 		data := tmplData{
@@ -164,19 +276,48 @@ func (s Server) SubmitEdits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.db.UpdateEntry(updatedEntry)
-	if err != nil {
-		s.abortWithError(w, err, http.StatusInternalServerError)
+	proposal, err := s.updateEntry(r, updatedEntry)
+	if errors.Is(err, sources.ErrVersionConflict) {
+		s.showConflict(w, updatedEntry, false)
+
+		return
+	} else if err != nil {
+		s.abortWithError(w, err, statusOrForbidden(err, http.StatusInternalServerError))
 
 		return
 	}
 
-	slog.Info(fmt.Sprintf("Updated entry: %+v\n", *updatedEntry))
+	if proposal != nil {
+		// the entry stays as it is until the change is approved
+		updatedEntry, err = s.db.GetEntry(id)
+		if err != nil {
+			s.abortWithError(w, err, http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	data := s.rowData(r, updatedEntry)
+	data.Warnings = s.warningsFor(updatedEntry.ID)
+	data.Proposed = proposal != nil
+
+	if err = s.templates.ExecuteTemplate(w, tmplRowPath, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// getVersion returns the version of the entry the user started changing, as sent
+// in the form or the URL query.
+func getVersion(r *http.Request) (uint32, error) {
+	version, err := strconv.ParseUint(r.FormValue(Version.string()), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid entry version: %w", err)
+	}
 
-	s.ResetView(w, r)
+	return uint32(version), nil
 }
 
-func createEntryFromForm(id uint16, r *http.Request) *sources.Entry {
+func createEntryFromForm(id uint64, r *http.Request) *sources.Entry {
 	return &sources.Entry{
 		ID:            id,
 		ReportingName: r.FormValue(ReportingName.string()),
@@ -201,38 +342,51 @@ func convertErrors(errs map[formField]string) map[string]string {
 }
 
 func (s Server) DeleteRow(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+	id, err := getIDFromURL(r)
 	if err != nil {
 		s.abortWithError(w, err, http.StatusBadRequest)
 
 		return
 	}
 
-	entry, err := s.db.DeleteEntry(uint16(id))
+	version, err := getVersion(r)
 	if err != nil {
-		s.abortWithError(w, err, http.StatusInternalServerError)
+		s.abortWithError(w, err, http.StatusBadRequest)
 
 		return
 	}
 
-	slog.Info(fmt.Sprintf("Deleted entry: %+v\n", *entry))
+	entry, proposal, err := s.deleteEntry(r, id, version)
+	if errors.Is(err, sources.ErrVersionConflict) {
+		s.showConflict(w, &sources.Entry{ID: id, Version: version}, true)
 
-	w.Header().Set("Content-Type", "text/html")
-	_, err = w.Write([]byte(fmt.Sprintf(`
-		<script>
-			document.getElementById('modal')?.remove();
-			document.querySelector('tr[data-id="%d"]')?.remove();
-		</script>
-	`, id)))
+		return
+	} else if err != nil {
+		s.abortWithError(w, err, statusOrForbidden(err, http.StatusInternalServerError))
 
-	if err != nil {
-		s.abortWithError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if proposal != nil {
+		s.showProposed(w, proposal)
+
+		return
 	}
+
+	s.showUndo(w, entry)
 }
 
-func (s Server) ShowAddRowForm(w http.ResponseWriter, _ *http.Request) {
-	err := s.templates.ExecuteTemplate(w, tmplAddRowPath, tmplData{Entry: &sources.Entry{}})
+func (s Server) ShowAddRowForm(w http.ResponseWriter, r *http.Request) {
+	if !s.canManageAny(r) {
+		s.abortWithError(w, ErrForbidden, http.StatusForbidden)
+
+		return
+	}
+
+	entry := &sources.Entry{}
+	setRequestor(r, entry)
+
+	err := s.templates.ExecuteTemplate(w, tmplAddRowPath, tmplData{Entry: entry, User: getUser(r)})
 	if err != nil {
 		s.abortWithError(w, err, http.StatusInternalServerError)
 	}
@@ -246,15 +400,17 @@ func (s Server) AddNewEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validationErrors := validateForm(r)
+	validationErrors := s.validateForm(r)
 
-	var dummyEntryID uint16 // will be set later
+	var dummyEntryID uint64 // will be set later
 	newEntry := createEntryFromForm(dummyEntryID, r)
+	setRequestor(r, newEntry)
 
 	if len(validationErrors) > 0 {
 		data := tmplData{
 			Entry:  newEntry,
 			Errors: convertErrors(validationErrors),
+			User:   getUser(r),
 		}
 
 		err := s.templates.ExecuteTemplate(w, tmplAddRowPath, data)
@@ -264,23 +420,33 @@ func (s Server) AddNewEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.db.AddEntry(newEntry)
+	proposal, err := s.addEntry(r, newEntry)
 	if err != nil {
-		s.abortWithError(w, err, http.StatusInternalServerError)
+		s.abortWithError(w, err, statusOrForbidden(err, http.StatusInternalServerError))
 
 		return
 	}
 
-	slog.Info(fmt.Sprintf("Added entry: %+v\n", *newEntry))
+	if proposal != nil {
+		s.showProposed(w, proposal)
+
+		return
+	}
 
 	// Set HX-Trigger to refresh the entry table
 	w.Header().Set("HX-Trigger", "entriesChanged")
+
+	// warn about the new entry in place of the form it was added with
+	err = s.templates.ExecuteTemplate(w, tmplWarningsPath, s.warningsFor(newEntry.ID))
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
 }
 
 func (s Server) OpenDeleteDialog(w http.ResponseWriter, r *http.Request) {
 	err := s.changeTemplate(w, r, tmplDeleteDialogPath)
 	if err != nil {
-		s.abortWithError(w, err, http.StatusBadRequest)
+		s.abortWithError(w, err, statusOrForbidden(err, http.StatusBadRequest))
 	}
 }
 
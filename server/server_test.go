@@ -2,6 +2,7 @@ package server
 
 import (
 	"backup-plan-ui/sources"
+	"backup-plan-ui/validation"
 	"context"
 	"fmt"
 	"html/template"
@@ -145,6 +146,13 @@ func TestSubmitEdits(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
+			current, err := s.db.GetEntry(test.entry.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			test.entry.Version = current.Version
+
 			form := createFormFromEntry(test.entry)
 			req := makeFormRequest(form, fmt.Sprintf("/actions/submit/%d", test.entry.ID), fmt.Sprintf("%d", test.entry.ID))
 
@@ -163,6 +171,8 @@ func TestSubmitEdits(t *testing.T) {
 				t.Error(err)
 			}
 
+			test.entry.Version++
+
 			if ok, err := So(*changedEntry, ShouldResemble, test.entry); !ok {
 				t.Error(err)
 			}
@@ -181,7 +191,7 @@ func TestDeleteRow(t *testing.T) {
 		entry := originalEntries[0]
 
 		w := httptest.NewRecorder()
-		r := makeRequest(entry.ID)
+		r := makeVersionedRequest(entry.ID, entry.Version)
 
 		s.DeleteRow(w, r)
 
@@ -215,6 +225,25 @@ func TestDeleteRow(t *testing.T) {
 		}
 	})
 
+	t.Run("You must provide the current version", func(t *testing.T) {
+		entry := originalEntries[1]
+
+		w := httptest.NewRecorder()
+		r := makeVersionedRequest(entry.ID, entry.Version+1)
+
+		s.DeleteRow(w, r)
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		if ok, err := So(body, ShouldContainSubstring, "Conflicting change"); !ok {
+			t.Error(err)
+		}
+
+		if _, err := s.db.GetEntry(entry.ID); err != nil {
+			t.Error(err)
+		}
+	})
+
 	t.Run("You must provide an ID currently in use", func(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -222,6 +251,7 @@ func TestDeleteRow(t *testing.T) {
 		ctx.URLParams.Add("id", fmt.Sprint(sources.NumTestDataRows))
 
 		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
+		r.URL.RawQuery = Version.string() + "=0"
 
 		s.DeleteRow(w, r)
 
@@ -243,7 +273,7 @@ func TestDeleteRow(t *testing.T) {
 	})
 }
 
-func makeRequest(id uint16) *http.Request {
+func makeRequest(id uint64) *http.Request {
 	r := httptest.NewRequest(http.MethodGet, "/", nil)
 	ctx := chi.NewRouteContext()
 	ctx.URLParams.Add("id", fmt.Sprint(id))
@@ -251,7 +281,16 @@ func makeRequest(id uint16) *http.Request {
 	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, ctx))
 }
 
+func makeVersionedRequest(id uint64, version uint32) *http.Request {
+	r := makeRequest(id)
+	r.URL.RawQuery = fmt.Sprintf("%s=%d", Version, version)
+
+	return r
+}
+
 func TestValidateForm(t *testing.T) {
+	s, _ := createServer(t)
+
 	exampleFormData := map[formField]string{
 		ReportingName: "test_report",
 		ReportingRoot: "/a/b/c/d/e",
@@ -273,10 +312,10 @@ func TestValidateForm(t *testing.T) {
 			data[fieldName] = ""
 
 			req := makeFormRequest(createFormFromMap(data), "/", "")
-			errors := validateForm(req)
+			errors := s.validateForm(req)
 
-			if got := errors[fieldName]; got != ErrBlankInput {
-				t.Errorf("Expected error for %s: %q, got: %q", fieldName, ErrBlankInput, got)
+			if got := errors[fieldName]; got != validation.ErrBlankInput {
+				t.Errorf("Expected error for %s: %q, got: %q", fieldName, validation.ErrBlankInput, got)
 			}
 		})
 	}
@@ -291,7 +330,7 @@ func TestValidateForm(t *testing.T) {
 			name:        "Invalid instruction input",
 			formData:    cloneAndUpdateMapValue(exampleFormData, Instruction, "invalid"),
 			KeyForErr:   Instruction,
-			expectedErr: ErrInvalidInstruction,
+			expectedErr: "Input must be backup, nobackup or tempbackup",
 		},
 		{
 			name: "Ignore when instruction is not backup",
@@ -302,19 +341,42 @@ func TestValidateForm(t *testing.T) {
 				return data
 			}(),
 			KeyForErr:   Ignore,
-			expectedErr: ErrIgnoreWithoutBackup,
+			expectedErr: validation.ErrIgnoreWithoutBackup,
+		},
+		{
+			name: "Match when instruction is not backup",
+			formData: func() map[formField]string {
+				data := cloneMap(exampleFormData)
+				data[Instruction] = "tempbackup"
+				data[Match] = "*.txt"
+				return data
+			}(),
+			KeyForErr:   Match,
+			expectedErr: validation.ErrMatchWithoutBackup,
+		},
+		{
+			name: "Malformed patterns are named",
+			formData: func() map[formField]string {
+				data := cloneMap(exampleFormData)
+				data[Instruction] = "backup"
+				data[Ignore] = "*.txt [abc *.log x\\"
+				return data
+			}(),
+			KeyForErr: Ignore,
+			expectedErr: validation.ErrInvalidPattern + `"[abc": syntax error in pattern; ` +
+				validation.ErrInvalidPattern + `"x\\": syntax error in pattern`,
 		},
 		{
 			name:        "Reporting root doesn't start with a slash",
 			formData:    cloneAndUpdateMapValue(exampleFormData, ReportingRoot, "some/dir"),
 			KeyForErr:   ReportingRoot,
-			expectedErr: ErrRootWithoutSlash,
+			expectedErr: validation.ErrRootWithoutSlash,
 		},
 		{
 			name:        "Reporting root not deep enough",
 			formData:    cloneAndUpdateMapValue(exampleFormData, ReportingRoot, "/a/shallow/dir"),
 			KeyForErr:   ReportingRoot,
-			expectedErr: ErrReportingRootNotDeepEnough,
+			expectedErr: fmt.Sprintf(validation.ErrReportingRootNotDeepEnough, 5),
 		},
 		{
 			name: "Directory not in Reporting root",
@@ -325,14 +387,14 @@ func TestValidateForm(t *testing.T) {
 				return data
 			}(),
 			KeyForErr:   Directory,
-			expectedErr: ErrDirectoryNotInRoot,
+			expectedErr: validation.ErrDirectoryNotInRoot,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			req := makeFormRequest(createFormFromMap(test.formData), "/", "")
-			errors := validateForm(req)
+			errors := s.validateForm(req)
 
 			if got := errors[test.KeyForErr]; got != test.expectedErr {
 				t.Errorf("Expected error for %s: %q, got: %q", test.KeyForErr, test.expectedErr, got)
@@ -430,6 +492,7 @@ func createFormFromEntry(entry sources.Entry) url.Values {
 	form.Set(Ignore.string(), entry.Ignore)
 	form.Set(Requestor.string(), entry.Requestor)
 	form.Set(Faculty.string(), entry.Faculty)
+	form.Set(Version.string(), fmt.Sprint(entry.Version))
 
 	return form
 }
@@ -455,6 +518,7 @@ func createServer(t *testing.T) (Server, []*sources.Entry) {
 	server := Server{
 		db:        sources.CSVSource{Path: dbPath},
 		templates: templates,
+		changes:   newChangeHub(),
 	}
 
 	return server, entries
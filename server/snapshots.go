@@ -0,0 +1,281 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	tmplSnapshotsPath    = "snapshots.html"
+	tmplSnapshotListPath = "snapshot_list.html"
+	tmplSnapshotDiffPath = "snapshot_diff.html"
+
+	// latestSnapshot refers to the most recently published snapshot wherever a
+	// snapshot number is expected.
+	latestSnapshot = "latest"
+
+	querySnapshot = "snapshot"
+	queryDiffFrom = "from"
+	queryDiffTo   = "to"
+)
+
+var (
+	ErrNotPublisher = errors.New("only admins may publish snapshots")
+
+	errInvalidSnapshot = errors.New("invalid snapshot number")
+)
+
+// planChange is a difference between two versions of the plan.
+type planChange struct {
+	Kind   sources.ChangeKind `json:"kind"`
+	Before *sources.Entry     `json:"before,omitempty"`
+	After  *sources.Entry     `json:"after,omitempty"`
+	Fields []fieldChange      `json:"-"`
+}
+
+// Entry returns the entry the change is about: as it is after the change, or as
+// it was for removals.
+func (c planChange) Entry() *sources.Entry {
+	if c.After == nil {
+		return c.Before
+	}
+
+	return c.After
+}
+
+// planDiff is what changed between a snapshot and a later snapshot, or the
+// working copy if To is nil.
+type planDiff struct {
+	From    uint64       `json:"from"`
+	To      *uint64      `json:"to"`
+	Changes []planChange `json:"changes"`
+}
+
+type snapshotsTmplData struct {
+	User       *User
+	CSRFToken  string
+	CanPublish bool
+	Snapshots  []*sources.Snapshot
+	Published  *sources.Snapshot
+}
+
+// diffPlans lists the entries added, changed and removed between before and
+// after, by ID. Entries whose fields are the same are unchanged, whatever their
+// version.
+func diffPlans(before, after []*sources.Entry) []planChange {
+	previous := make(map[uint64]*sources.Entry, len(before))
+	for _, entry := range before {
+		previous[entry.ID] = entry
+	}
+
+	var changes []planChange
+
+	for _, entry := range after {
+		old, ok := previous[entry.ID]
+		delete(previous, entry.ID)
+
+		switch fields := diffEntries(old, entry); {
+		case !ok:
+			changes = append(changes, planChange{Kind: sources.ChangeAdd, After: entry, Fields: fields})
+		case len(fields) > 0:
+			changes = append(changes, planChange{Kind: sources.ChangeUpdate, Before: old, After: entry, Fields: fields})
+		}
+	}
+
+	for _, entry := range previous {
+		changes = append(changes, planChange{Kind: sources.ChangeRemove, Before: entry,
+			Fields: diffEntries(entry, nil)})
+	}
+
+	slices.SortFunc(changes, func(a, b planChange) int { return cmp.Compare(a.Entry().ID, b.Entry().ID) })
+
+	return changes
+}
+
+// getSnapshot returns the snapshot with the given number, or the latest one.
+func (s Server) getSnapshot(ref string) (*sources.Snapshot, error) {
+	if ref == latestSnapshot {
+		return sources.LatestSnapshot(s.db)
+	}
+
+	number, err := strconv.ParseUint(ref, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", errInvalidSnapshot, ref)
+	}
+
+	return s.db.GetSnapshot(number)
+}
+
+// publishSnapshot publishes the plan as it is now as the next snapshot.
+func (s Server) publishSnapshot(r *http.Request) (*sources.Snapshot, error) {
+	if !s.canPublish(r) {
+		return nil, ErrNotPublisher
+	}
+
+	snapshot, err := s.db.PublishSnapshot(getActor(r))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info(fmt.Sprintf("Published snapshot %d of %d entries by %s", snapshot.Number, snapshot.EntryCount,
+		snapshot.PublishedBy))
+
+	return snapshot, nil
+}
+
+// diffSnapshot compares the snapshot numbered from with the snapshot numbered
+// to, or the working copy if to is empty.
+func (s Server) diffSnapshot(from, to string) (*planDiff, error) {
+	before, err := s.getSnapshot(from)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &planDiff{From: before.Number}
+
+	var after []*sources.Entry
+
+	if to == "" {
+		after, err = s.db.ReadAll()
+	} else {
+		var snapshot *sources.Snapshot
+
+		if snapshot, err = s.getSnapshot(to); err == nil {
+			diff.To = &snapshot.Number
+			after = snapshot.Entries
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	diff.Changes = diffPlans(before.Entries, after)
+
+	return diff, nil
+}
+
+// ShowSnapshots renders a page listing the published snapshots of the plan,
+// newest first, from which they can be compared and the plan published again.
+func (s Server) ShowSnapshots(w http.ResponseWriter, r *http.Request) {
+	data := snapshotsTmplData{User: getUser(r), CSRFToken: getCSRFToken(r)}
+
+	s.renderSnapshots(w, r, tmplSnapshotsPath, data)
+}
+
+// PublishSnapshot publishes the plan as it is now, and lists the snapshots again
+// with the new one.
+func (s Server) PublishSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := s.publishSnapshot(r)
+	if err != nil {
+		s.abortWithError(w, err, statusForError(err))
+
+		return
+	}
+
+	s.renderSnapshots(w, r, tmplSnapshotListPath, snapshotsTmplData{Published: snapshot})
+}
+
+func (s Server) renderSnapshots(w http.ResponseWriter, r *http.Request, tmpl string, data snapshotsTmplData) {
+	snapshots, err := s.db.ListSnapshots()
+	if err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+
+		return
+	}
+
+	slices.Reverse(snapshots)
+
+	data.Snapshots = snapshots
+	data.CanPublish = s.canPublish(r)
+
+	if err = s.templates.ExecuteTemplate(w, tmpl, data); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// ShowSnapshotDiff renders the differences between the snapshots given in the
+// query, or between a snapshot and the working copy.
+func (s Server) ShowSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	diff, err := s.diffSnapshot(r.FormValue(queryDiffFrom), r.FormValue(queryDiffTo))
+	if err != nil {
+		s.abortWithError(w, err, statusForError(err))
+
+		return
+	}
+
+	if err = s.templates.ExecuteTemplate(w, tmplSnapshotDiffPath, diff); err != nil {
+		s.abortWithError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// APIListSnapshots responds with the published snapshots without their entries,
+// oldest first.
+func (s Server) APIListSnapshots(w http.ResponseWriter, _ *http.Request) {
+	snapshots, err := s.db.ListSnapshots()
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if snapshots == nil {
+		snapshots = []*sources.Snapshot{}
+	}
+
+	s.writeJSON(w, http.StatusOK, snapshots)
+}
+
+// APIPublishSnapshot publishes the plan as it is now and responds with the new
+// snapshot.
+func (s Server) APIPublishSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := s.publishSnapshot(r)
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, snapshot.Number))
+	s.writeJSON(w, http.StatusCreated, snapshot)
+}
+
+// APIGetSnapshot responds with the snapshot with the number in the URL, or the
+// latest one, with its entries.
+func (s Server) APIGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := s.getSnapshot(chi.URLParam(r, "number"))
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, snapshot)
+}
+
+// APIDiffSnapshots responds with the changes between the snapshots given in the
+// query, or between a snapshot and the working copy.
+func (s Server) APIDiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	diff, err := s.diffSnapshot(params.Get(queryDiffFrom), params.Get(queryDiffTo))
+	if err != nil {
+		s.writeJSONError(w, err, statusForError(err))
+
+		return
+	}
+
+	if diff.Changes == nil {
+		diff.Changes = []planChange{}
+	}
+
+	s.writeJSON(w, http.StatusOK, diff)
+}
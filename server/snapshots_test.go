@@ -0,0 +1,184 @@
+package server
+
+import (
+	"backup-plan-ui/sources"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gocarina/gocsv"
+	. "github.com/smarty/assertions"
+)
+
+func TestSnapshots(t *testing.T) {
+	s, originalEntries := createServer(t)
+
+	t.Run("Nothing is published at first", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.APIGetSnapshot(w, makeSnapshotRequest(latestSnapshot))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusNotFound); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.ShowSnapshots(w, httptest.NewRequest(http.MethodGet, "/snapshots", nil))
+
+		if ok, err := So(getBodyAndCheckStatusOK(t, w), ShouldContainSubstring, "has not been published"); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Publishing lists the new snapshot", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.PublishSnapshot(w, httptest.NewRequest(http.MethodPost, "/snapshots/publish", nil))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"Published snapshot 1 of 3 entries", `href="export/csv?snapshot=1"`} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+	})
+
+	renamed := *originalEntries[0]
+	renamed.ReportingName = "renamed"
+
+	if err := s.db.UpdateEntry(&renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Published snapshots do not change with the working copy", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.APIGetSnapshot(w, makeSnapshotRequest(latestSnapshot))
+
+		var snapshot sources.Snapshot
+
+		if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(snapshot.Entries, ShouldResemble, originalEntries); !ok {
+			t.Error(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.ExportEntries(w, makeExportRequest("csv", "?snapshot=latest"))
+
+		var entries []*sources.Entry
+		if err := gocsv.UnmarshalString(getBodyAndCheckStatusOK(t, w), &entries); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Snapshots can be compared with the working copy and each other", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.ShowSnapshotDiff(w, httptest.NewRequest(http.MethodGet, "/snapshots/diff?from=1&to=", nil))
+
+		body := getBodyAndCheckStatusOK(t, w)
+
+		for _, expected := range []string{"1 changes from snapshot 1 to the working copy", "renamed"} {
+			if ok, err := So(body, ShouldContainSubstring, expected); !ok {
+				t.Error(err)
+			}
+		}
+
+		w = httptest.NewRecorder()
+		s.APIPublishSnapshot(w, httptest.NewRequest(http.MethodPost, "/api/v1/snapshots", nil))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusCreated); !ok {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		s.APIDiffSnapshots(w, httptest.NewRequest(http.MethodGet, "/api/v1/snapshots/diff?from=1&to=2", nil))
+
+		var diff planDiff
+
+		if err := json.Unmarshal(w.Body.Bytes(), &diff); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(diff.Changes, ShouldHaveLength, 1); !ok {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(diff.Changes[0].Kind, ShouldEqual, sources.ChangeUpdate); !ok {
+			t.Error(err)
+		}
+
+		if ok, err := So(diff.Changes[0].After.ReportingName, ShouldEqual, renamed.ReportingName); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Snapshots are referred to by number", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.APIGetSnapshot(w, makeSnapshotRequest("first"))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusBadRequest); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Only admins may publish under an access policy", func(t *testing.T) {
+		s.access = &AccessPolicy{Default: Grant{Role: RoleEditor, Faculties: []string{"group"}}}
+		defer func() { s.access = nil }()
+
+		w := httptest.NewRecorder()
+		s.APIPublishSnapshot(w, withUser(httptest.NewRequest(http.MethodPost, "/api/v1/snapshots", nil),
+			&User{Name: "eve"}))
+
+		if ok, err := So(w.Code, ShouldEqual, http.StatusForbidden); !ok {
+			t.Error(err)
+		}
+	})
+}
+
+func TestDiffPlans(t *testing.T) {
+	_, entries := createServer(t)
+
+	changed := *entries[1]
+	changed.Instruction = sources.NoBackup
+
+	bumped := *entries[2]
+	bumped.Version++
+
+	added := *entries[0]
+	added.ID = 7
+
+	changes := diffPlans(entries, []*sources.Entry{&changed, &added, &bumped})
+
+	if ok, err := So(changes, ShouldHaveLength, 3); !ok {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []sources.ChangeKind{sources.ChangeRemove, sources.ChangeUpdate, sources.ChangeAdd} {
+		if ok, err := So(changes[i].Kind, ShouldEqual, expected); !ok {
+			t.Error(err)
+		}
+	}
+
+	if ok, err := So(changes[1].Fields, ShouldResemble, []fieldChange{
+		{Field: Instruction.string(), Before: string(entries[1].Instruction), After: string(sources.NoBackup)},
+	}); !ok {
+		t.Error(err)
+	}
+}
+
+func makeSnapshotRequest(number string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/snapshots/"+number, nil)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("number", number)
+
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
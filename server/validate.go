@@ -3,96 +3,37 @@ package server
 import (
 	"backup-plan-ui/sources"
 	"net/http"
-	"path/filepath"
-	"strings"
+	"net/url"
 )
 
-type FormValidator struct {
-	request *http.Request
-	errors  map[formField]string
+func (s Server) validateForm(r *http.Request) map[formField]string {
+	return s.validateEntry(createEntryFromForm(0, r))
 }
 
-const (
-	ErrBlankInput                 = "You cannot leave this field blank"
-	ErrInvalidInstruction         = "Input must be backup, tempBackup or noBackup"
-	ErrIgnoreWithoutBackup        = "Ignore can only be used with the backup instruction"
-	ErrDirectoryNotInRoot         = "Directory must be inside Reporting root"
-	ErrReportingRootNotDeepEnough = "Reporting Root must be atleast five levels deep"
-	ErrRootWithoutSlash           = "Reporting Root must start with a slash (/)"
-)
+// validateEntry applies the same rules as validateForm to an entry that did not
+// come from an HTML form, e.g. one decoded from a JSON request body.
+func (s Server) validateEntry(entry *sources.Entry) map[formField]string {
+	errs := s.policy.Validate(entry)
+	fieldErrors := make(map[formField]string, len(errs))
 
-func validateForm(r *http.Request) map[formField]string {
-	fv := FormValidator{
-		request: r,
-		errors:  make(map[formField]string),
+	for field, err := range errs {
+		fieldErrors[formField(field)] = err
 	}
 
-	fv.validateNonBlankInputs()
-	fv.validateInstructionAndIgnore()
-	fv.validateDirectoryAndRoot()
-
-	return fv.errors
+	return fieldErrors
 }
 
-func (fv FormValidator) validateNonBlankInputs() {
-	requiredFields := []formField{ReportingName, ReportingRoot, Directory,
-		Instruction, Requestor, Faculty}
-
-	for _, requiredField := range requiredFields {
-		if fv.getFormValue(requiredField) == "" {
-			fv.errors[requiredField] = ErrBlankInput
-		}
-	}
-}
-
-func (fv FormValidator) getFormValue(field formField) string {
-	return fv.request.FormValue(field.string())
-}
+func valuesFromEntry(entry *sources.Entry) url.Values {
+	values := make(url.Values)
 
-func (fv FormValidator) validateInstructionAndIgnore() {
-	instr := sources.Instruction(fv.getFormValue(Instruction))
-	ignore := fv.getFormValue(Ignore)
+	values.Set(ReportingName.string(), entry.ReportingName)
+	values.Set(ReportingRoot.string(), entry.ReportingRoot)
+	values.Set(Directory.string(), entry.Directory)
+	values.Set(Instruction.string(), string(entry.Instruction))
+	values.Set(Match.string(), entry.Match)
+	values.Set(Ignore.string(), entry.Ignore)
+	values.Set(Requestor.string(), entry.Requestor)
+	values.Set(Faculty.string(), entry.Faculty)
 
-	if instr != sources.Backup && instr != sources.TempBackup && instr != sources.NoBackup {
-		fv.addErrorIfNew(Instruction, ErrInvalidInstruction)
-	}
-
-	if ignore != "" && instr != sources.Backup {
-		fv.addErrorIfNew(Ignore, ErrIgnoreWithoutBackup)
-	}
-}
-
-func (fv FormValidator) addErrorIfNew(field formField, err string) {
-	if _, exists := fv.errors[field]; !exists {
-		fv.errors[field] = err
-	}
-}
-
-func (fv FormValidator) validateDirectoryAndRoot() {
-	reportingRoot := fv.getFormValue(ReportingRoot)
-	dir := fv.getFormValue(Directory)
-
-	if !strings.HasSuffix(dir, "/") {
-		dir += "/"
-	}
-
-	if !strings.HasPrefix(reportingRoot, "/") {
-		fv.addErrorIfNew(ReportingRoot, ErrRootWithoutSlash)
-	}
-
-	rel, err := filepath.Rel(reportingRoot, dir)
-	if err != nil || strings.HasPrefix(rel, "../") || rel == ".." {
-		fv.addErrorIfNew(Directory, ErrDirectoryNotInRoot)
-	}
-
-	depth := 0
-	for _, part := range strings.Split(reportingRoot, string(filepath.Separator)) {
-		if part != "" {
-			depth++
-		}
-	}
-
-	if depth < 5 {
-		fv.addErrorIfNew(ReportingRoot, ErrReportingRootNotDeepEnough)
-	}
+	return values
 }
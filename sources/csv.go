@@ -1,17 +1,94 @@
 package sources
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gocarina/gocsv"
 )
 
+const (
+	historyFileSuffix   = ".history.jsonl"
+	proposalsFileSuffix = ".proposals.jsonl"
+	snapshotsFileSuffix = ".snapshots.jsonl"
+	nextIDFileSuffix    = ".next-id"
+	lockFileSuffix      = ".lock"
+	defaultFileMode     = 0644
+)
+
+// CSVSource stores the plan in a CSV file. Changes are serialised between every
+// CSVSource for the same file in this process, and between processes through an
+// advisory lock on a sidecar lock file. The file is replaced atomically, so
+// readers and crashes never see a partially written plan.
 type CSVSource struct {
 	Path string
 }
 
+// csvLocks holds a *sync.Mutex for each CSV file path in use.
+var csvLocks sync.Map
+
+func (c CSVSource) callAndLogError(f func() error) {
+	err := f()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+// lock blocks until no other goroutine or process is changing the CSV file, and
+// returns a function that must be called to let them continue.
+func (c CSVSource) lock() (func() error, error) {
+	key, err := filepath.Abs(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, _ := csvLocks.LoadOrStore(key, &sync.Mutex{})
+	mu := m.(*sync.Mutex)
+
+	mu.Lock()
+
+	unlockFile, err := lockFile(c.Path + lockFileSuffix)
+	if err != nil {
+		mu.Unlock()
+
+		return nil, err
+	}
+
+	return func() error {
+		defer mu.Unlock()
+
+		return unlockFile()
+	}, nil
+}
+
 func (c CSVSource) ReadAll() ([]*Entry, error) {
-	in, err := os.Open(c.Path)
+	entries, err := c.readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(entries, (*Entry).isDeleted), nil
+}
+
+// readEntries returns every entry in the CSV file, including tombstones.
+func (c CSVSource) readEntries() ([]*Entry, error) {
+	return readEntriesFile(c.Path)
+}
+
+func readEntriesFile(path string) ([]*Entry, error) {
+	in, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -25,7 +102,17 @@ func (c CSVSource) ReadAll() ([]*Entry, error) {
 	return entries, err
 }
 
-func (c CSVSource) GetEntry(id uint16) (*Entry, error) {
+// Query selects a page of entries in memory, as CSV files are always read whole.
+func (c CSVSource) Query(query *Query) (*Page, error) {
+	entries, err := c.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Apply(entries)
+}
+
+func (c CSVSource) GetEntry(id uint64) (*Entry, error) {
 	entries, err := c.ReadAll()
 	if err != nil {
 		return nil, err
@@ -36,9 +123,11 @@ func (c CSVSource) GetEntry(id uint16) (*Entry, error) {
 	return entry, err
 }
 
-func getMatchingEntryWithID(id uint16, entries []*Entry) (*Entry, int, error) {
+// getMatchingEntryWithID returns the entry with the given ID and its index,
+// ignoring tombstones.
+func getMatchingEntryWithID(id uint64, entries []*Entry) (*Entry, int, error) {
 	for i, entry := range entries {
-		if entry.ID == id {
+		if entry.ID == id && !entry.isDeleted() {
 			return entry, i, nil
 		}
 	}
@@ -47,34 +136,126 @@ func getMatchingEntryWithID(id uint16, entries []*Entry) (*Entry, int, error) {
 }
 
 func (c CSVSource) UpdateEntry(newEntry *Entry) error {
-	entries, err := c.ReadAll()
+	unlock, err := c.lock()
 	if err != nil {
 		return err
 	}
 
-	_, index, err := getMatchingEntryWithID(newEntry.ID, entries)
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
 	if err != nil {
 		return err
 	}
 
+	oldEntry, index, err := getMatchingEntryWithID(newEntry.ID, entries)
+	if err != nil {
+		return err
+	}
+
+	if oldEntry.Version != newEntry.Version {
+		return ErrVersionConflict
+	}
+
+	newEntry.Version++
+	newEntry.DeletedAt = nil
 	entries[index] = newEntry
 
 	return c.writeEntries(entries)
 }
 
+// writeEntries replaces the contents of the CSV file with the given entries. They
+// are first written to a temporary file in the same directory, which is synced
+// to disk and then renamed over the CSV file.
 func (c CSVSource) writeEntries(entries []*Entry) error {
-	out, err := os.Create(c.Path)
+	return c.replaceFile(c.Path, func(w io.Writer) error {
+		return gocsv.Marshal(&entries, w)
+	})
+}
+
+// replaceFile replaces the file at path, which is the CSV file or one of its
+// sidecars, with what write writes, with the same permissions as the CSV file.
+func (c CSVSource) replaceFile(path string, write func(w io.Writer) error) error {
+	mode, err := c.fileMode()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+
+	out, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
 
-	defer out.Close()
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	if err = write(out); err != nil {
+		return err
+	}
 
-	return gocsv.MarshalFile(&entries, out)
+	if err = out.Chmod(mode); err != nil {
+		return err
+	}
+
+	if err = out.Sync(); err != nil {
+		return err
+	}
+
+	if err = out.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(out.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
 }
 
-func (c CSVSource) DeleteEntry(id uint16) (*Entry, error) {
-	entries, err := c.ReadAll()
+// fileMode returns the permissions of the existing CSV file, so that replacing
+// it does not change them.
+func (c CSVSource) fileMode() (fs.FileMode, error) {
+	info, err := os.Stat(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultFileMode, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return info.Mode().Perm(), nil
+}
+
+// syncDir makes sure a rename within the directory has reached the disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	if err = d.Sync(); err != nil {
+		d.Close()
+
+		return err
+	}
+
+	return d.Close()
+}
+
+func (c CSVSource) DeleteEntry(id uint64, version uint32) (*Entry, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
 	if err != nil {
 		return nil, err
 	}
@@ -84,37 +265,543 @@ func (c CSVSource) DeleteEntry(id uint16) (*Entry, error) {
 		return nil, err
 	}
 
-	entries = append(entries[:index], entries[index+1:]...)
+	if entry.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	deletedAt := time.Now().UTC()
+
+	tombstone := *entry
+	tombstone.DeletedAt = &deletedAt
+	entries[index] = &tombstone
 
 	return entry, c.writeEntries(entries)
 }
 
+func (c CSVSource) ListDeleted() ([]*Entry, error) {
+	entries, err := c.readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := slices.DeleteFunc(entries, func(e *Entry) bool { return !e.isDeleted() })
+
+	slices.SortStableFunc(deleted, func(a, b *Entry) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+
+	return deleted, nil
+}
+
+func (c CSVSource) RestoreEntry(id uint64) (*Entry, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID != id {
+			continue
+		}
+
+		if !entry.isDeleted() {
+			return nil, ErrNotDeleted
+		}
+
+		entry.DeletedAt = nil
+
+		return entry, c.writeEntries(entries)
+	}
+
+	return nil, ErrNoEntry
+}
+
+func (c CSVSource) PurgeDeleted(before time.Time) (int, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return 0, err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	mark, err := c.readNextID()
+	if err != nil {
+		return 0, err
+	}
+
+	next := getNextID(entries, mark)
+	total := len(entries)
+
+	entries = slices.DeleteFunc(entries, func(e *Entry) bool {
+		return e.isDeleted() && e.DeletedAt.Before(before)
+	})
+
+	purged := total - len(entries)
+	if purged == 0 {
+		return 0, nil
+	}
+
+	// remember the IDs of the purged entries were used, so they are not given
+	// to new entries
+	if next > mark {
+		if err = c.writeNextID(next); err != nil {
+			return 0, err
+		}
+	}
+
+	return purged, c.writeEntries(entries)
+}
+
 func (c CSVSource) AddEntry(newEntry *Entry) error {
-	entries, err := c.ReadAll()
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
+	if err != nil {
+		return err
+	}
+
+	mark, err := c.readNextID()
 	if err != nil {
 		return err
 	}
 
-	newEntry.ID = c.getNextID(entries)
+	newEntry.ID = getNextID(entries, mark)
+	newEntry.DeletedAt = nil
 
 	entries = append(entries, newEntry)
 
 	return c.writeEntries(entries)
 }
 
-func (c CSVSource) getNextID(entries []*Entry) uint16 {
-	used := make(map[uint16]struct{}, len(entries))
+// ApplyChanges makes every change to the entries in memory, and only writes them
+// to the CSV file if all of them could be made.
+func (c CSVSource) ApplyChanges(changes *ChangeSet) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.readEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, newEntry := range changes.Update {
+		index, err := liveIndexWithVersion(newEntry.ID, newEntry.Version, entries)
+		if err != nil {
+			return err
+		}
+
+		updated := *newEntry
+		updated.Version++
+		updated.DeletedAt = nil
+		entries[index] = &updated
+	}
+
+	deletedAt := time.Now().UTC()
+
+	for _, entry := range changes.Delete {
+		index, err := liveIndexWithVersion(entry.ID, entry.Version, entries)
+		if err != nil {
+			return err
+		}
+
+		tombstone := *entries[index]
+		tombstone.DeletedAt = &deletedAt
+		entries[index] = &tombstone
+	}
+
+	mark, err := c.readNextID()
+	if err != nil {
+		return err
+	}
+
+	for _, newEntry := range changes.Add {
+		newEntry.ID = getNextID(entries, mark)
+		newEntry.DeletedAt = nil
+
+		entries = append(entries, newEntry)
+	}
+
+	if err = c.writeEntries(entries); err != nil {
+		return err
+	}
+
+	for _, newEntry := range changes.Update {
+		newEntry.Version++
+		newEntry.DeletedAt = nil
+	}
+
+	return nil
+}
+
+// liveIndexWithVersion returns the index of the entry with the given ID, or an
+// error naming the entry if it does not have the given version.
+func liveIndexWithVersion(id uint64, version uint32, entries []*Entry) (int, error) {
+	entry, index, err := getMatchingEntryWithID(id, entries)
+	if err == nil && entry.Version != version {
+		err = ErrVersionConflict
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("entry %d: %w", id, err)
+	}
+
+	return index, nil
+}
+
+// getNextID returns the ID for a new entry: one more than the highest ID of the
+// entries, or the high-water mark left by purged entries if that is higher.
+func getNextID(entries []*Entry, mark uint64) uint64 {
+	next := mark
+
 	for _, entry := range entries {
-		used[entry.ID] = struct{}{}
+		if entry.ID >= next {
+			next = entry.ID + 1
+		}
+	}
+
+	return next
+}
+
+// nextIDPath returns the path of the sidecar file holding the lowest ID that may
+// be given to a new entry, so the IDs of purged entries are never used again.
+func (c CSVSource) nextIDPath() string {
+	return c.Path + nextIDFileSuffix
+}
+
+// readNextID returns the high-water mark of the IDs used, or 0 if no entries
+// have been purged.
+func (c CSVSource) readNextID() (uint64, error) {
+	contents, err := os.ReadFile(c.nextIDPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+}
+
+func (c CSVSource) writeNextID(next uint64) error {
+	return c.replaceFile(c.nextIDPath(), func(w io.Writer) error {
+		_, err := fmt.Fprintln(w, next)
+
+		return err
+	})
+}
+
+// historyPath returns the path of the sidecar file holding the change history
+// of the plan, one JSON encoded HistoryRecord per line.
+func (c CSVSource) historyPath() string {
+	return c.Path + historyFileSuffix
+}
+
+func (c CSVSource) AddHistory(record *HistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	out, err := os.OpenFile(c.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(append(line, '\n'))
+	if err == nil {
+		err = out.Sync()
+	}
+
+	if err != nil {
+		out.Close()
+
+		return err
+	}
+
+	return out.Close()
+}
+
+func (c CSVSource) GetHistory(entryID uint64) ([]*HistoryRecord, error) {
+	records, err := c.readHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	return filterHistory(records, entryID), nil
+}
+
+func (c CSVSource) readHistory() ([]*HistoryRecord, error) {
+	return readJSONLines[HistoryRecord](c.historyPath())
+}
+
+// readJSONLines reads a sidecar file holding one JSON encoded value per line,
+// returning none if the file does not exist.
+func readJSONLines[T any](path string) ([]*T, error) {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	// Find gaps
-	for i := range uint16(len(used)) {
-		_, found := used[i]
-		if !found {
-			return i
+	defer in.Close()
+
+	var values []*T
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var value T
+
+		if err = json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			return nil, err
 		}
+
+		values = append(values, &value)
+	}
+
+	return values, scanner.Err()
+}
+
+// proposalsPath returns the path of the sidecar file holding the proposed
+// changes to the plan, one JSON encoded Proposal per line.
+func (c CSVSource) proposalsPath() string {
+	return c.Path + proposalsFileSuffix
+}
+
+func (c CSVSource) readProposals() ([]*Proposal, error) {
+	return readJSONLines[Proposal](c.proposalsPath())
+}
+
+func (c CSVSource) writeProposals(proposals []*Proposal) error {
+	return c.replaceFile(c.proposalsPath(), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+
+		for _, proposal := range proposals {
+			if err := enc.Encode(proposal); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (c CSVSource) AddProposal(proposal *Proposal) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	proposals, err := c.readProposals()
+	if err != nil {
+		return err
+	}
+
+	proposal.ID = 1
+
+	if len(proposals) > 0 {
+		proposal.ID = proposals[len(proposals)-1].ID + 1
+	}
+
+	return c.writeProposals(append(proposals, proposal))
+}
+
+func (c CSVSource) ListProposals(status ProposalStatus) ([]*Proposal, error) {
+	proposals, err := c.readProposals()
+	if err != nil {
+		return nil, err
+	}
+
+	return filterProposals(proposals, status), nil
+}
+
+func (c CSVSource) GetProposal(id uint64) (*Proposal, error) {
+	proposals, err := c.readProposals()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proposal := range proposals {
+		if proposal.ID == id {
+			return proposal, nil
+		}
+	}
+
+	return nil, ErrNoProposal
+}
+
+func (c CSVSource) ReviewProposal(id uint64, status ProposalStatus, reviewer string) (*Proposal, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	proposals, err := c.readProposals()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proposal := range proposals {
+		if proposal.ID != id {
+			continue
+		}
+
+		if err = proposal.review(status, reviewer); err != nil {
+			return nil, err
+		}
+
+		return proposal, c.writeProposals(proposals)
+	}
+
+	return nil, ErrNoProposal
+}
+
+func (c CSVSource) ReopenProposal(id uint64) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	proposals, err := c.readProposals()
+	if err != nil {
+		return err
+	}
+
+	for _, proposal := range proposals {
+		if proposal.ID != id {
+			continue
+		}
+
+		if err = proposal.reopen(); err != nil {
+			return err
+		}
+
+		return c.writeProposals(proposals)
+	}
+
+	return ErrNoProposal
+}
+
+// snapshotsPath returns the path of the sidecar file listing the published
+// snapshots of the plan, one JSON encoded Snapshot without its entries per line.
+func (c CSVSource) snapshotsPath() string {
+	return c.Path + snapshotsFileSuffix
+}
+
+// snapshotPath returns the path of the CSV file holding the entries of the
+// snapshot with the given number, which can be used as a plan itself.
+func (c CSVSource) snapshotPath(number uint64) string {
+	return fmt.Sprintf("%s.snapshot-%d.csv", c.Path, number)
+}
+
+// PublishSnapshot writes the entries of the snapshot before listing it, so a
+// listed snapshot always has its entries.
+func (c CSVSource) PublishSnapshot(publisher string) (*Snapshot, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.callAndLogError(unlock)
+
+	entries, err := c.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := c.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := newSnapshot(publisher, entries)
+	snapshot.Number = 1
+
+	if len(snapshots) > 0 {
+		snapshot.Number = snapshots[len(snapshots)-1].Number + 1
+	}
+
+	err = c.replaceFile(c.snapshotPath(snapshot.Number), func(w io.Writer) error {
+		return gocsv.Marshal(&snapshot.Entries, w)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listed := *snapshot
+	listed.Entries = nil
+
+	return snapshot, c.replaceFile(c.snapshotsPath(), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+
+		for _, s := range append(snapshots, &listed) {
+			if err := enc.Encode(s); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (c CSVSource) ListSnapshots() ([]*Snapshot, error) {
+	return readJSONLines[Snapshot](c.snapshotsPath())
+}
+
+func (c CSVSource) GetSnapshot(number uint64) (*Snapshot, error) {
+	snapshots, err := c.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Number != number {
+			continue
+		}
+
+		snapshot.Entries, err = readEntriesFile(c.snapshotPath(number))
+
+		return snapshot, err
 	}
 
-	return uint16(len(used))
+	return nil, ErrNoSnapshot
 }
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package sources
+
+// lockFile is a no-op on platforms without flock; changes are then only
+// serialised within this process.
+func lockFile(string) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package sources
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on the file at path, creating it if
+// necessary, blocking until the lock is available. Other processes using the
+// same lock file are excluded until the returned unlock function is called.
+func lockFile(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return func() error {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+			f.Close()
+
+			return err
+		}
+
+		return f.Close()
+	}, nil
+}
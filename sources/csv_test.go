@@ -2,8 +2,11 @@ package sources
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/smarty/assertions"
 )
@@ -16,6 +19,14 @@ func TestCSVSource_ReadAll(t *testing.T) {
 	testDataSourceReadAll(t, csvSource, entries)
 }
 
+func TestCSVSource_Query(t *testing.T) {
+	entries, testPath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: testPath}
+
+	testDataSourceQuery(t, csvSource, entries)
+}
+
 func TestCSVSource_GetEntry(t *testing.T) {
 	entries, testPath := CreateTestCSV(t)
 
@@ -32,10 +43,18 @@ func TestCSVSource_UpdateEntry(t *testing.T) {
 	testDataSourceUpdateEntry(t, csvSource, entries)
 }
 
+func TestCSVSource_VersionConflict(t *testing.T) {
+	entries, testPath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: testPath}
+
+	testDataSourceVersionConflict(t, csvSource, entries)
+}
+
 func TestCSVSource_DeleteEntry(t *testing.T) {
 	testCases := []struct {
 		name    string
-		entryID uint16
+		entryID uint64
 		wantErr error
 	}{
 		{"Delete first entry", 0, nil},
@@ -62,6 +81,38 @@ func TestCSVSource_DeleteEntry(t *testing.T) {
 	}
 }
 
+func TestCSVSource_SoftDelete(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: filePath}
+
+	testDataSourceSoftDelete(t, csvSource, entries)
+}
+
+func TestCSVSource_ApplyChanges(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: filePath}
+
+	testDataSourceApplyChanges(t, csvSource, entries)
+}
+
+func TestCSVSource_Proposals(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: filePath}
+
+	testDataSourceProposals(t, csvSource, entries)
+}
+
+func TestCSVSource_Snapshots(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: filePath}
+
+	testDataSourceSnapshots(t, csvSource, entries)
+}
+
 func TestCSVSource_AddEntry(t *testing.T) {
 	entries, filePath := CreateTestCSV(t)
 
@@ -70,6 +121,14 @@ func TestCSVSource_AddEntry(t *testing.T) {
 	testDataSourceAddEntry(t, csvSource, entries)
 }
 
+func TestCSVSource_History(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: filePath}
+
+	testDataSourceHistory(t, csvSource, entries)
+}
+
 func TestCSVSource_WriteEntries(t *testing.T) {
 	entries, _ := CreateTestCSV(t)
 
@@ -95,10 +154,110 @@ func TestCSVSource_WriteEntries(t *testing.T) {
 	}
 }
 
+func TestCSVSource_WriteEntriesReplacesAtomically(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	if err := os.Chmod(filePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	csvSource := CSVSource{Path: filePath}
+
+	err := csvSource.writeEntries(entries[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dirEntries, err := os.ReadDir(filepath.Dir(filePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(dirEntries, ShouldHaveLength, 1); !ok {
+		t.Error(err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(info.Mode().Perm(), ShouldEqual, os.FileMode(0640)); !ok {
+		t.Error(err)
+	}
+}
+
+func TestCSVSource_ConcurrentChanges(t *testing.T) {
+	entries, filePath := CreateTestCSV(t)
+
+	const numAdditions = 20
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, numAdditions+1)
+
+	for i := range numAdditions {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			newEntry := *entries[0]
+			newEntry.ReportingName = fmt.Sprintf("concurrent_%d", i)
+
+			// a new CSVSource for every goroutine, as the server does
+			errs <- CSVSource{Path: filePath}.AddEntry(&newEntry)
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		updated := *entries[1]
+		updated.ReportingName = "concurrent_update"
+
+		errs <- CSVSource{Path: filePath}.UpdateEntry(&updated)
+	}()
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := CSVSource{Path: filePath}.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored, ShouldHaveLength, NumTestDataRows+numAdditions); !ok {
+		t.Fatal(err)
+	}
+
+	ids := make(map[uint64]bool)
+	for _, entry := range stored {
+		ids[entry.ID] = true
+	}
+
+	if ok, err := So(ids, ShouldHaveLength, NumTestDataRows+numAdditions); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(stored[1].ReportingName, ShouldEqual, "concurrent_update"); !ok {
+		t.Error(err)
+	}
+}
+
 func TestGetNextID(t *testing.T) {
 	tests := []struct {
 		entries    []*Entry
-		expectedID uint16
+		mark       uint64
+		expectedID uint64
 	}{
 		{
 			entries:    []*Entry{{ID: 0}, {ID: 1}, {ID: 2}},
@@ -106,22 +265,54 @@ func TestGetNextID(t *testing.T) {
 		},
 		{
 			entries:    []*Entry{{ID: 0}, {ID: 2}, {ID: 3}},
-			expectedID: 1,
+			expectedID: 4,
 		},
 		{
 			entries:    []*Entry{{ID: 1}, {ID: 5}, {ID: 6}},
+			mark:       3,
+			expectedID: 7,
+		},
+		{
+			entries:    []*Entry{{ID: 0}, {ID: 1}},
+			mark:       5,
+			expectedID: 5,
+		},
+		{
 			expectedID: 0,
 		},
 	}
 
-	csvSource := CSVSource{}
-
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("Expect %d", test.expectedID), func(t *testing.T) {
-			id := csvSource.getNextID(test.entries)
+			id := getNextID(test.entries, test.mark)
 			if ok, err := So(id, ShouldEqual, test.expectedID); !ok {
 				t.Error(err)
 			}
 		})
 	}
 }
+
+func TestCSVSource_PurgedIDsAreNotReused(t *testing.T) {
+	entries, testPath := CreateTestCSV(t)
+
+	csvSource := CSVSource{Path: testPath}
+
+	last := entries[len(entries)-1]
+
+	if _, err := csvSource.DeleteEntry(last.ID, last.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := csvSource.PurgeDeleted(time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	added := *entries[0]
+	if err := csvSource.AddEntry(&added); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(added.ID, ShouldEqual, last.ID+1); !ok {
+		t.Error(err)
+	}
+}
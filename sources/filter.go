@@ -0,0 +1,193 @@
+package sources
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Filter selects and orders entries. Empty fields do not restrict the entries
+// selected.
+type Filter struct {
+	// Search matches entries containing the text in any of their text fields,
+	// ignoring case.
+	Search string
+
+	Instruction Instruction
+
+	// Faculty and Requestor match entries with exactly that value, ignoring case.
+	Faculty   string
+	Requestor string
+
+	// ReportingRootPrefix matches entries whose ReportingRoot starts with it.
+	ReportingRootPrefix string
+
+	// SortBy is the csv name of the column to sort by, e.g. "directory". Entries
+	// are sorted by ID if it is empty, and by ID within equal values otherwise.
+	SortBy     string
+	Descending bool
+}
+
+// Query selects a page of the entries matching a filter. A Limit of 0 selects
+// every entry after the Offset.
+type Query struct {
+	Filter
+
+	Limit  int
+	Offset int
+}
+
+// Page holds the entries selected by a Query, and the Total number of entries
+// matching its filter ignoring the Limit and Offset.
+type Page struct {
+	Entries []*Entry
+	Total   int
+}
+
+var (
+	ErrInvalidSortColumn = errors.New("cannot sort by unknown column")
+	ErrInvalidPage       = errors.New("limit and offset cannot be negative")
+)
+
+// sortColumns maps the names entries can be sorted by to a function returning
+// the value to sort on.
+var sortColumns = map[string]func(*Entry) string{
+	"reporting_name": func(e *Entry) string { return e.ReportingName },
+	"reporting_root": func(e *Entry) string { return e.ReportingRoot },
+	"directory":      func(e *Entry) string { return e.Directory },
+	"instruction":    func(e *Entry) string { return string(e.Instruction) },
+	"match":          func(e *Entry) string { return e.Match },
+	"ignore":         func(e *Entry) string { return e.Ignore },
+	"requestor":      func(e *Entry) string { return e.Requestor },
+	"faculty":        func(e *Entry) string { return e.Faculty },
+}
+
+const sortByID = "id"
+
+// Validate returns an error if the query cannot be applied.
+func (q *Query) Validate() error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return ErrInvalidPage
+	}
+
+	return q.Filter.Validate()
+}
+
+// Apply returns the page of entries selected by the query.
+func (q *Query) Apply(entries []*Entry) (*Page, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	matching, err := q.Filter.Apply(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Total: len(matching)}
+
+	start := min(q.Offset, len(matching))
+	end := len(matching)
+
+	if q.Limit > 0 {
+		end = min(start+q.Limit, end)
+	}
+
+	if start < end {
+		page.Entries = matching[start:end]
+	}
+
+	return page, nil
+}
+
+// Validate returns an error if the filter cannot be applied.
+func (f *Filter) Validate() error {
+	if f.SortBy == "" || f.SortBy == sortByID {
+		return nil
+	}
+
+	if _, ok := sortColumns[f.SortBy]; !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidSortColumn, f.SortBy)
+	}
+
+	return nil
+}
+
+// Matches returns true if the entry is selected by the filter.
+func (f *Filter) Matches(e *Entry) bool {
+	if f.Instruction != "" && e.Instruction != f.Instruction {
+		return false
+	}
+
+	if f.Faculty != "" && !strings.EqualFold(e.Faculty, f.Faculty) {
+		return false
+	}
+
+	if f.Requestor != "" && !strings.EqualFold(e.Requestor, f.Requestor) {
+		return false
+	}
+
+	if !strings.HasPrefix(e.ReportingRoot, f.ReportingRootPrefix) {
+		return false
+	}
+
+	return f.matchesSearch(e)
+}
+
+func (f *Filter) matchesSearch(e *Entry) bool {
+	if f.Search == "" {
+		return true
+	}
+
+	search := strings.ToLower(f.Search)
+
+	for _, value := range []string{e.ReportingName, e.ReportingRoot, e.Directory, string(e.Instruction),
+		e.Match, e.Ignore, e.Requestor, e.Faculty} {
+		if strings.Contains(strings.ToLower(value), search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply returns the entries selected by the filter, in its order.
+func (f *Filter) Apply(entries []*Entry) ([]*Entry, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	var matching []*Entry
+
+	for _, entry := range entries {
+		if f.Matches(entry) {
+			matching = append(matching, entry)
+		}
+	}
+
+	f.sort(matching)
+
+	return matching, nil
+}
+
+func (f *Filter) sort(entries []*Entry) {
+	value, ok := sortColumns[f.SortBy]
+	if !ok {
+		value = func(*Entry) string { return "" }
+	}
+
+	slices.SortStableFunc(entries, func(a, b *Entry) int {
+		c := cmp.Compare(value(a), value(b))
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+
+		if f.Descending {
+			return -c
+		}
+
+		return c
+	})
+}
@@ -0,0 +1,58 @@
+package sources
+
+import (
+	"time"
+)
+
+type Operation string
+
+const (
+	OpAdd     Operation = "add"
+	OpUpdate  Operation = "update"
+	OpDelete  Operation = "delete"
+	OpRestore Operation = "restore"
+)
+
+// HistoryRecord describes a single change to an entry. Before is nil for added
+// and restored entries and After is nil for deleted ones.
+type HistoryRecord struct {
+	EntryID   uint64    `json:"entry_id"`
+	Operation Operation `json:"operation"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+	Before    *Entry    `json:"before,omitempty"`
+	After     *Entry    `json:"after,omitempty"`
+}
+
+// NewHistoryRecord creates a record of a change made by actor now. The ID of the
+// changed entry is taken from whichever of before and after is set.
+func NewHistoryRecord(op Operation, actor string, before, after *Entry) *HistoryRecord {
+	record := &HistoryRecord{
+		Operation: op,
+		Actor:     actor,
+		Timestamp: time.Now().UTC(),
+		Before:    before,
+		After:     after,
+	}
+
+	switch {
+	case after != nil:
+		record.EntryID = after.ID
+	case before != nil:
+		record.EntryID = before.ID
+	}
+
+	return record
+}
+
+func filterHistory(records []*HistoryRecord, entryID uint64) []*HistoryRecord {
+	var matching []*HistoryRecord
+
+	for _, record := range records {
+		if record.EntryID == entryID {
+			matching = append(matching, record)
+		}
+	}
+
+	return matching
+}
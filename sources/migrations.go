@@ -0,0 +1,293 @@
+package sources
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// migration changes the schema of the tables for a SQLSource from the previous
+// version to its version. Migrations must cope with tables created before
+// migrations were recorded, which may already contain their change.
+type migration struct {
+	version     int
+	description string
+	up          func(sq SQLSource, tx migrationTx) error
+}
+
+// migrationTx is the transaction a migration is made and recorded in.
+type migrationTx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// migrations are applied in order; append to the list to change the schema and
+// never alter a migration once released.
+var migrations = []migration{
+	{1, "create entries table", execMigration(createEntriesTableTmpl)},
+	{2, "add version to entries", SQLSource.addVersionColumnIfMissing},
+	{3, "create history table", execMigration(createHistoryTableTmpl)},
+	{4, "rename keep and skip to match and ignore", SQLSource.renameKeepAndSkipIfPresent},
+	{5, "widen ids to 64 bits", SQLSource.widenIDColumns},
+	{6, "add deleted_at to entries", execMigration(addDeletedAtStmt)},
+	{7, "create proposals table", execMigration(createProposalsTableTmpl)},
+	{8, "create snapshot tables", execMigration(createSnapshotsTableTmpl, createSnapshotEntriesTableTmpl)},
+	{9, "widen proposal ids to 64 bits", SQLSource.widenProposalIDColumns},
+	{10, "widen snapshot ids to 64 bits", SQLSource.widenSnapshotIDColumns},
+}
+
+// LatestSchemaVersion is the schema version this program expects the database
+// to have.
+var LatestSchemaVersion = migrations[len(migrations)-1].version
+
+const createMigrationsTableStmt = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	table_name VARCHAR(255) NOT NULL,
+	version INTEGER NOT NULL,
+	description TEXT,
+	applied_at TEXT,
+	PRIMARY KEY (table_name, version)
+)`
+
+const (
+	schemaVersionStmt   = "SELECT COALESCE(MAX(version), 0) FROM schema_migrations WHERE table_name = ?"
+	recordMigrationStmt = `INSERT INTO schema_migrations (table_name, version, description, applied_at)
+			          VALUES (?, ?, ?, ?)`
+	forgetMigrationsStmt = "DELETE FROM schema_migrations WHERE table_name = ?"
+)
+
+// createEntriesTableTmpl is the original schema of the entries table; later
+// migrations change it. Its placeholders are the table name, the dialect's
+// auto increment term and the instructions.
+const createEntriesTableTmpl = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id INTEGER PRIMARY KEY %[2]s,
+	reporting_name TEXT,
+	reporting_root TEXT,
+	directory TEXT,
+	instruction TEXT CHECK ( instruction IN ('%[4]s', '%[5]s', '%[6]s') ),
+	keep TEXT,
+	skip TEXT,
+	requestor TEXT,
+	faculty TEXT
+)`
+
+const createHistoryTableTmpl = `CREATE TABLE IF NOT EXISTS %[3]s (
+	id INTEGER PRIMARY KEY %[2]s,
+	entry_id INTEGER,
+	operation TEXT,
+	actor TEXT,
+	changed_at TEXT,
+	before_entry TEXT,
+	after_entry TEXT
+)`
+
+// createProposalsTableTmpl holds the changes waiting for review. Its placeholders
+// are those of createEntriesTableTmpl, followed by the proposals table name.
+const createProposalsTableTmpl = `CREATE TABLE IF NOT EXISTS %[7]s (
+	id INTEGER PRIMARY KEY %[2]s,
+	operation TEXT,
+	proposer TEXT,
+	proposed_at TEXT,
+	before_entry TEXT,
+	after_entry TEXT,
+	status VARCHAR(16),
+	reviewer TEXT,
+	reviewed_at TEXT
+)`
+
+// createSnapshotsTableTmpl lists the published snapshots, and
+// createSnapshotEntriesTableTmpl holds the entries of each. Their placeholders
+// are those of createProposalsTableTmpl, followed by the two table names.
+const createSnapshotsTableTmpl = `CREATE TABLE IF NOT EXISTS %[8]s (
+	id INTEGER PRIMARY KEY %[2]s,
+	published_by TEXT,
+	published_at TEXT,
+	entry_count INTEGER
+)`
+
+const createSnapshotEntriesTableTmpl = `CREATE TABLE IF NOT EXISTS %[9]s (
+	snapshot_id INTEGER NOT NULL,
+	id BIGINT NOT NULL,
+	reporting_name TEXT,
+	reporting_root TEXT,
+	directory TEXT,
+	instruction TEXT,
+	"match" TEXT,
+	"ignore" TEXT,
+	requestor TEXT,
+	faculty TEXT,
+	version INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (snapshot_id, id)
+)`
+
+const (
+	selectNoRowsStmt = "SELECT * FROM %s LIMIT 0"
+
+	renameKeepStmt = `ALTER TABLE %[1]s RENAME COLUMN keep TO "match"`
+	renameSkipStmt = `ALTER TABLE %[1]s RENAME COLUMN skip TO "ignore"`
+
+	// addDeletedAtStmt adds the column marking entries as deleted, holding the
+	// time in deletedAtFormat.
+	addDeletedAtStmt = "ALTER TABLE %[1]s ADD COLUMN deleted_at VARCHAR(32)"
+)
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this program supports")
+
+// execMigration returns a migration running the given statement templates.
+func execMigration(tmpls ...string) func(sq SQLSource, tx migrationTx) error {
+	return func(sq SQLSource, tx migrationTx) error {
+		for _, tmpl := range tmpls {
+			stmt := fmt.Sprintf(tmpl, sq.tableName, sq.dialect.autoIncrement, sq.historyTableName(),
+				Backup, NoBackup, TempBackup, sq.proposalsTableName(), sq.snapshotsTableName(),
+				sq.snapshotEntriesTableName())
+
+			if _, err := tx.Exec(sq.dialect.rebind(stmt)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// SchemaVersion returns the version of the schema of the tables, 0 if no
+// migrations have been applied to them.
+func (sq SQLSource) SchemaVersion() (int, error) {
+	if _, err := sq.exec(createMigrationsTableStmt); err != nil {
+		return 0, err
+	}
+
+	var version int
+
+	err := sq.queryRow(schemaVersionStmt, sq.tableName).Scan(&version)
+
+	return version, err
+}
+
+// Migrate brings the schema of the tables up to LatestSchemaVersion, creating
+// them if they do not exist. It returns ErrSchemaTooNew if the schema was
+// migrated by a newer version of this program.
+func (sq SQLSource) Migrate() error {
+	current, err := sq.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if current > LatestSchemaVersion {
+		return fmt.Errorf("%w: %s is at version %d, expected at most %d",
+			ErrSchemaTooNew, sq.tableName, current, LatestSchemaVersion)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err = sq.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+		}
+
+		slog.Info(fmt.Sprintf("Migrated %s to schema version %d: %s", sq.tableName, m.version, m.description))
+	}
+
+	return nil
+}
+
+// applyMigration makes the migration and records it in one transaction, so a
+// failed migration is not recorded, and a migration made by two programs at once
+// is only recorded by one. SQLite and PostgreSQL undo the changes of a failed
+// migration too; MySQL commits each change to the schema as it is made.
+func (sq SQLSource) applyMigration(m migration) (err error) {
+	tx, err := sq.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			sq.callAndLogError(tx.Rollback)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = m.up(sq, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sq.dialect.rebind(recordMigrationStmt), sq.tableName, m.version, m.description,
+		time.Now().UTC().Format(time.RFC3339Nano))
+
+	return err
+}
+
+// hasColumn returns true if the table has a column with the given name. It does
+// not fail when the column is missing, which would abort the transaction in
+// PostgreSQL.
+func (sq SQLSource) hasColumn(tx migrationTx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(selectNoRowsStmt, table))
+	if err != nil {
+		return false, err
+	}
+
+	defer sq.callAndLogError(rows.Close)
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(columns, func(name string) bool { return strings.EqualFold(name, column) }), nil
+}
+
+// widenIDColumns lets IDs grow beyond 32 bits; SQLite integers are already 64
+// bits wide.
+func (sq SQLSource) widenIDColumns(tx migrationTx) error {
+	return execMigration(sq.dialect.widenIDStmts...)(sq, tx)
+}
+
+// widenProposalIDColumns does the same for the proposals table, which was
+// created with 32-bit IDs.
+func (sq SQLSource) widenProposalIDColumns(tx migrationTx) error {
+	return execMigration(sq.dialect.widenProposalIDStmts...)(sq, tx)
+}
+
+// widenSnapshotIDColumns does the same for the snapshot tables.
+func (sq SQLSource) widenSnapshotIDColumns(tx migrationTx) error {
+	return execMigration(sq.dialect.widenSnapshotIDStmts...)(sq, tx)
+}
+
+// addVersionColumnIfMissing upgrades tables created before entries were
+// versioned.
+func (sq SQLSource) addVersionColumnIfMissing(tx migrationTx) error {
+	ok, err := sq.hasColumn(tx, sq.tableName, "version")
+	if err != nil || ok {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(addVersionStmt, sq.tableName))
+
+	return err
+}
+
+// renameKeepAndSkipIfPresent renames the pattern columns of tables created with
+// their old names, and leaves tables created with their new names alone.
+func (sq SQLSource) renameKeepAndSkipIfPresent(tx migrationTx) error {
+	for column, tmpl := range map[string]string{"keep": renameKeepStmt, "skip": renameSkipStmt} {
+		ok, err := sq.hasColumn(tx, sq.tableName, column)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			if err = execMigration(tmpl)(sq, tx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package sources
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestSQLSource_Migrate(t *testing.T) {
+	t.Run("New databases are migrated to the latest version", func(t *testing.T) {
+		_, sq := createTestSQLiteTable(t)
+		defer callAndLogError(t, sq.Close)
+
+		version, err := sq.SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(version, ShouldEqual, LatestSchemaVersion); !ok {
+			t.Error(err)
+		}
+
+		if err = sq.Migrate(); err != nil {
+			t.Errorf("migrating an up to date database should do nothing, got: %s", err)
+		}
+	})
+
+	t.Run("Legacy tables keep their entries", func(t *testing.T) {
+		sq, err := NewSQLiteSource(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer callAndLogError(t, sq.Close)
+
+		_, err = sq.db.Exec(`CREATE TABLE entries (id INTEGER PRIMARY KEY AUTOINCREMENT, reporting_name TEXT,
+			reporting_root TEXT, directory TEXT, instruction TEXT, keep TEXT, skip TEXT, requestor TEXT, faculty TEXT)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = sq.db.Exec(`INSERT INTO entries (reporting_name, reporting_root, directory, instruction, keep, skip,
+			requestor, faculty) VALUES ('legacy', '/a', '/a/b', 'backup', '*.txt', '*.log', 'user', 'group')`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = sq.Migrate(); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := sq.GetEntry(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := &Entry{ID: 1, ReportingName: "legacy", ReportingRoot: "/a", Directory: "/a/b",
+			Instruction: Backup, Match: "*.txt", Ignore: "*.log", Requestor: "user", Faculty: "group"}
+
+		if ok, err := So(entry, ShouldResemble, expected); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Legacy tables with the new column names are migrated", func(t *testing.T) {
+		sq, err := NewSQLiteSource(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer callAndLogError(t, sq.Close)
+
+		_, err = sq.db.Exec(`CREATE TABLE entries (id INTEGER PRIMARY KEY AUTOINCREMENT, reporting_name TEXT,
+			reporting_root TEXT, directory TEXT, instruction TEXT, "match" TEXT, "ignore" TEXT, requestor TEXT,
+			faculty TEXT)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = sq.Migrate(); err != nil {
+			t.Fatal(err)
+		}
+
+		entry := &Entry{ReportingName: "new", Directory: "/a/b", Instruction: Backup, Match: "*.txt"}
+		if err = sq.AddEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := sq.GetEntry(entry.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(stored, ShouldResemble, entry); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Failed migrations are not recorded", func(t *testing.T) {
+		sq, err := NewSQLiteSource(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer callAndLogError(t, sq.Close)
+
+		_, err = sq.db.Exec(`CREATE TABLE entries (id INTEGER PRIMARY KEY AUTOINCREMENT, reporting_name TEXT,
+			reporting_root TEXT, directory TEXT, instruction TEXT, "match" TEXT, "ignore" TEXT, requestor TEXT,
+			faculty TEXT, deleted_at TEXT)`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = sq.Migrate(); err == nil {
+			t.Fatal("expected adding deleted_at again to fail")
+		}
+
+		version, err := sq.SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(version, ShouldEqual, 5); !ok {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Databases migrated by a newer program are refused", func(t *testing.T) {
+		_, sq := createTestSQLiteTable(t)
+		defer callAndLogError(t, sq.Close)
+
+		_, err := sq.exec(recordMigrationStmt, sq.tableName, LatestSchemaVersion+1, "from the future", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sq.Migrate()
+		if !errors.Is(err, ErrSchemaTooNew) {
+			t.Errorf("expected ErrSchemaTooNew, got: %v", err)
+		}
+	})
+
+	t.Run("Dropped tables are created again", func(t *testing.T) {
+		_, sq := createTestSQLiteTable(t)
+		defer callAndLogError(t, sq.Close)
+
+		if err := sq.DropTable(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sq.CreateTable(); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := sq.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := So(entries, ShouldBeEmpty); !ok {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package sources
+
+import (
+	"errors"
+	"time"
+)
+
+type ProposalStatus string
+
+const (
+	ProposalPending  ProposalStatus = "pending"
+	ProposalApproved ProposalStatus = "approved"
+	ProposalRejected ProposalStatus = "rejected"
+)
+
+// Proposal is a change to the plan waiting for a reviewer to approve it before
+// it is made. Before is the entry as it was when the change was proposed, nil
+// for additions, and After is the entry as proposed, nil for deletions.
+type Proposal struct {
+	ID         uint64         `json:"id"`
+	Operation  Operation      `json:"operation"`
+	Proposer   string         `json:"proposer"`
+	ProposedAt time.Time      `json:"proposed_at"`
+	Before     *Entry         `json:"before,omitempty"`
+	After      *Entry         `json:"after,omitempty"`
+	Status     ProposalStatus `json:"status"`
+	Reviewer   string         `json:"reviewer,omitempty"`
+	ReviewedAt *time.Time     `json:"reviewed_at,omitempty"`
+}
+
+var (
+	ErrNoProposal = errors.New("proposal does not exist")
+
+	// ErrNotPending is returned when reviewing a proposal that has already been
+	// approved or rejected.
+	ErrNotPending = errors.New("proposal has already been reviewed")
+)
+
+// NewProposal creates a pending proposal made by proposer now.
+func NewProposal(op Operation, proposer string, before, after *Entry) *Proposal {
+	return &Proposal{
+		Operation:  op,
+		Proposer:   proposer,
+		ProposedAt: time.Now().UTC(),
+		Before:     before,
+		After:      after,
+		Status:     ProposalPending,
+	}
+}
+
+// Entry returns the entry the proposal is about: as proposed, or as it was for
+// deletions.
+func (p *Proposal) Entry() *Entry {
+	if p.After == nil {
+		return p.Before
+	}
+
+	return p.After
+}
+
+// review records the decision of the reviewer on the proposal, if it is still
+// pending.
+func (p *Proposal) review(status ProposalStatus, reviewer string) error {
+	if p.Status != ProposalPending {
+		return ErrNotPending
+	}
+
+	reviewedAt := time.Now().UTC()
+
+	p.Status = status
+	p.Reviewer = reviewer
+	p.ReviewedAt = &reviewedAt
+
+	return nil
+}
+
+// reopen makes the approved proposal pending again.
+func (p *Proposal) reopen() error {
+	if p.Status != ProposalApproved {
+		return ErrNotPending
+	}
+
+	p.Status = ProposalPending
+	p.Reviewer = ""
+	p.ReviewedAt = nil
+
+	return nil
+}
+
+func filterProposals(proposals []*Proposal, status ProposalStatus) []*Proposal {
+	var matching []*Proposal
+
+	for _, proposal := range proposals {
+		if proposal.Status == status {
+			matching = append(matching, proposal)
+		}
+	}
+
+	return matching
+}
@@ -0,0 +1,52 @@
+package sources
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Snapshot is an immutable copy of the plan, published so that the backup
+// tooling reads a finished plan rather than one being edited. Snapshots are
+// numbered from 1 in the order they were published.
+type Snapshot struct {
+	Number      uint64    `json:"number"`
+	PublishedBy string    `json:"published_by"`
+	PublishedAt time.Time `json:"published_at"`
+	EntryCount  int       `json:"entry_count"`
+
+	// Entries are the live entries of the plan when it was published, ordered by
+	// ID. They are left out when listing snapshots.
+	Entries []*Entry `json:"entries,omitempty"`
+}
+
+var ErrNoSnapshot = errors.New("snapshot does not exist")
+
+// newSnapshot returns a snapshot of the entries published by publisher now,
+// sorting them by ID.
+func newSnapshot(publisher string, entries []*Entry) *Snapshot {
+	slices.SortFunc(entries, func(a, b *Entry) int { return cmp.Compare(a.ID, b.ID) })
+
+	return &Snapshot{
+		PublishedBy: publisher,
+		PublishedAt: time.Now().UTC(),
+		EntryCount:  len(entries),
+		Entries:     entries,
+	}
+}
+
+// LatestSnapshot returns the most recently published snapshot in the
+// DataSource with its entries, or ErrNoSnapshot if none has been published.
+func LatestSnapshot(ds DataSource) (*Snapshot, error) {
+	snapshots, err := ds.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	return ds.GetSnapshot(snapshots[len(snapshots)-1].Number)
+}
@@ -2,14 +2,69 @@ package sources
 
 import (
 	"errors"
+	"time"
 )
 
+// DataSource stores the entries of the plan. Deleted entries are kept as
+// tombstones, which only ListDeleted, RestoreEntry and PurgeDeleted see, until
+// they are purged.
 type DataSource interface {
 	ReadAll() ([]*Entry, error)
-	GetEntry(id uint16) (*Entry, error)
+	Query(query *Query) (*Page, error)
+	GetEntry(id uint64) (*Entry, error)
 	UpdateEntry(newEntry *Entry) error
-	DeleteEntry(id uint16) (*Entry, error)
+
+	// DeleteEntry marks the entry deleted if it has the given version, and
+	// returns it as it was before it was deleted.
+	DeleteEntry(id uint64, version uint32) (*Entry, error)
 	AddEntry(entry *Entry) error
+
+	// ListDeleted returns the deleted entries, most recently deleted first.
+	ListDeleted() ([]*Entry, error)
+
+	// RestoreEntry brings a deleted entry back with the same ID, returning
+	// ErrNotDeleted if it was not deleted.
+	RestoreEntry(id uint64) (*Entry, error)
+
+	// PurgeDeleted permanently removes the entries deleted before the given
+	// time, returning how many were removed.
+	PurgeDeleted(before time.Time) (int, error)
+
+	// ApplyChanges makes every change in the ChangeSet, or none of them if any
+	// cannot be made. Added entries are given their IDs, and updated entries
+	// their new versions.
+	ApplyChanges(changes *ChangeSet) error
+
+	AddHistory(record *HistoryRecord) error
+	GetHistory(entryID uint64) ([]*HistoryRecord, error)
+
+	// AddProposal stores a new proposal, giving it its ID.
+	AddProposal(proposal *Proposal) error
+
+	// ListProposals returns the proposals with the given status, oldest first.
+	ListProposals(status ProposalStatus) ([]*Proposal, error)
+	GetProposal(id uint64) (*Proposal, error)
+
+	// ReviewProposal records the decision of the reviewer on a pending proposal,
+	// returning the reviewed proposal, or ErrNotPending if it had already been
+	// reviewed.
+	ReviewProposal(id uint64, status ProposalStatus, reviewer string) (*Proposal, error)
+
+	// ReopenProposal makes an approved proposal pending again, for when its
+	// change could not be made after all.
+	ReopenProposal(id uint64) error
+
+	// PublishSnapshot stores a copy of the live entries as the next numbered
+	// snapshot, and returns it.
+	PublishSnapshot(publisher string) (*Snapshot, error)
+
+	// ListSnapshots returns every published snapshot without its entries, oldest
+	// first.
+	ListSnapshots() ([]*Snapshot, error)
+
+	// GetSnapshot returns the snapshot with the given number with its entries, or
+	// ErrNoSnapshot.
+	GetSnapshot(number uint64) (*Snapshot, error)
 }
 
 type Instruction string
@@ -21,15 +76,63 @@ const (
 )
 
 type Entry struct {
-	ReportingName string      `csv:"reporting_name"`
-	ReportingRoot string      `csv:"reporting_root"`
-	Directory     string      `csv:"directory"`
-	Instruction   Instruction `csv:"instruction"`
-	Match         string      `csv:"match"`
-	Ignore        string      `csv:"ignore"`
-	Requestor     string      `csv:"requestor"`
-	Faculty       string      `csv:"faculty"`
-	ID            uint16      `csv:"id"`
+	ReportingName string      `csv:"reporting_name" yaml:"reporting_name"`
+	ReportingRoot string      `csv:"reporting_root" yaml:"reporting_root"`
+	Directory     string      `csv:"directory" yaml:"directory"`
+	Instruction   Instruction `csv:"instruction" yaml:"instruction"`
+	Match         string      `csv:"match" yaml:"match"`
+	Ignore        string      `csv:"ignore" yaml:"ignore"`
+	Requestor     string      `csv:"requestor" yaml:"requestor"`
+	Faculty       string      `csv:"faculty" yaml:"faculty"`
+	ID            uint64      `csv:"id" yaml:"id"`
+	Version       uint32      `csv:"version" yaml:"version"`
+
+	// DeletedAt is when the entry was deleted, nil unless it is a tombstone.
+	DeletedAt *time.Time `csv:"deleted_at,omitempty" json:",omitempty" yaml:"deleted_at,omitempty"`
+}
+
+// ChangeSet is a batch of changes to the plan, made together by ApplyChanges.
+type ChangeSet struct {
+	Add []*Entry
+
+	// Update holds the entries replacing those with the same IDs, which must
+	// still have the same versions.
+	Update []*Entry
+
+	// Delete holds the entries to delete, which must still have the same
+	// versions.
+	Delete []*Entry
 }
 
-var ErrNoEntry = errors.New("entry does not exist")
+// ChangeKind is the kind of change one version of the plan makes to another,
+// such as an imported file to the live plan, or a snapshot to an earlier one.
+type ChangeKind string
+
+const (
+	ChangeAdd    ChangeKind = "add"
+	ChangeUpdate ChangeKind = "update"
+	ChangeRemove ChangeKind = "remove"
+)
+
+var (
+	ErrNoEntry = errors.New("entry does not exist")
+
+	// ErrVersionConflict is returned when changing an entry that has been changed
+	// by someone else since the given version was read.
+	ErrVersionConflict = errors.New("entry has been changed by someone else")
+
+	ErrNotDeleted = errors.New("entry has not been deleted")
+)
+
+// isDeleted returns true if the entry is a tombstone.
+func (e *Entry) isDeleted() bool {
+	return e.DeletedAt != nil
+}
+
+// undeleted returns a copy of the entry without its deletion time.
+func (e *Entry) undeleted() *Entry {
+	entry := *e
+	entry.DeletedAt = nil
+
+	return &entry
+}
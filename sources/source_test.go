@@ -3,6 +3,7 @@ package sources
 import (
 	"errors"
 	"testing"
+	"time"
 
 	. "github.com/smarty/assertions"
 )
@@ -84,8 +85,8 @@ func testDataSourceUpdateEntry(t *testing.T, ds DataSource, originalEntries []*E
 
 }
 
-func testDataSourceDeleteEntry(t *testing.T, ds DataSource, originalEntry *Entry, idToDelete uint16, expectedErr error) {
-	entry, err := ds.DeleteEntry(idToDelete)
+func testDataSourceDeleteEntry(t *testing.T, ds DataSource, originalEntry *Entry, idToDelete uint64, expectedErr error) {
+	entry, err := ds.DeleteEntry(idToDelete, 0)
 	if !errors.Is(err, expectedErr) {
 		t.Fatal(err)
 	}
@@ -114,6 +115,299 @@ func testDataSourceDeleteEntry(t *testing.T, ds DataSource, originalEntry *Entry
 	}
 }
 
+func testDataSourceSoftDelete(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	entry := originalEntries[0]
+
+	if _, err := ds.RestoreEntry(entry.ID); !errors.Is(err, ErrNotDeleted) {
+		t.Fatalf("expected %v, got %v", ErrNotDeleted, err)
+	}
+
+	beforeDelete := time.Now()
+
+	if _, err := ds.DeleteEntry(entry.ID, entry.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.GetEntry(entry.ID); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("expected %v, got %v", ErrNoEntry, err)
+	}
+
+	if err := ds.UpdateEntry(entry); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("expected %v, got %v", ErrNoEntry, err)
+	}
+
+	deleted, err := ds.ListDeleted()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(deleted, ShouldHaveLength, 1); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(deleted[0].ID, ShouldEqual, entry.ID); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(*deleted[0].DeletedAt, ShouldHappenOnOrAfter, beforeDelete.Truncate(time.Microsecond)); !ok {
+		t.Error(err)
+	}
+
+	restored, err := ds.RestoreEntry(entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(restored, ShouldResemble, entry); !ok {
+		t.Error(err)
+	}
+
+	testDataSourceReadAll(t, ds, originalEntries)
+
+	if _, err = ds.DeleteEntry(entry.ID, entry.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := ds.PurgeDeleted(beforeDelete.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(purged, ShouldEqual, 0); !ok {
+		t.Error(err)
+	}
+
+	purged, err = ds.PurgeDeleted(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(purged, ShouldEqual, 1); !ok {
+		t.Error(err)
+	}
+
+	if _, err = ds.RestoreEntry(entry.ID); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("expected %v, got %v", ErrNoEntry, err)
+	}
+
+	entries, err := ds.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(entries, ShouldHaveLength, len(originalEntries)-1); !ok {
+		t.Error(err)
+	}
+}
+
+func testDataSourceVersionConflict(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	firstEdit := *originalEntries[0]
+	firstEdit.ReportingName = "first_edit"
+
+	if err := ds.UpdateEntry(&firstEdit); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(firstEdit.Version, ShouldEqual, originalEntries[0].Version+1); !ok {
+		t.Error(err)
+	}
+
+	staleEdit := *originalEntries[0]
+	staleEdit.ReportingName = "stale_edit"
+
+	if err := ds.UpdateEntry(&staleEdit); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected %v, got %v", ErrVersionConflict, err)
+	}
+
+	if _, err := ds.DeleteEntry(staleEdit.ID, staleEdit.Version); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected %v, got %v", ErrVersionConflict, err)
+	}
+
+	stored, err := ds.GetEntry(firstEdit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored, ShouldResemble, &firstEdit); !ok {
+		t.Error(err)
+	}
+
+	deleted, err := ds.DeleteEntry(firstEdit.ID, firstEdit.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(deleted, ShouldResemble, &firstEdit); !ok {
+		t.Error(err)
+	}
+}
+
+func testDataSourceApplyChanges(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	updated := *originalEntries[0]
+	updated.ReportingName = "test_project_imported"
+
+	added := *originalEntries[0]
+	added.ReportingName = "test_project_added"
+
+	stale := *originalEntries[1]
+	stale.Version++
+
+	err := ds.ApplyChanges(&ChangeSet{
+		Add:    []*Entry{&added},
+		Update: []*Entry{&updated},
+		Delete: []*Entry{&stale},
+	})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("expected %v, got %v", ErrVersionConflict, err)
+	}
+
+	entries, err := ds.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(entries, ShouldResemble, originalEntries); !ok {
+		t.Fatal(err)
+	}
+
+	err = ds.ApplyChanges(&ChangeSet{
+		Add:    []*Entry{&added},
+		Update: []*Entry{&updated},
+		Delete: []*Entry{originalEntries[1]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(updated.Version, ShouldEqual, originalEntries[0].Version+1); !ok {
+		t.Error(err)
+	}
+
+	entries, err = ds.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Entry{&updated}
+	want = append(want, originalEntries[2:]...)
+	want = append(want, &added)
+
+	if ok, err := So(entries, ShouldResemble, want); !ok {
+		t.Error(err)
+	}
+
+	if _, err = ds.GetEntry(originalEntries[1].ID); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("expected %v, got %v", ErrNoEntry, err)
+	}
+}
+
+func testDataSourceProposals(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	if _, err := ds.GetProposal(1); !errors.Is(err, ErrNoProposal) {
+		t.Fatalf("expected %v, got %v", ErrNoProposal, err)
+	}
+
+	changed := *originalEntries[0]
+	changed.Instruction = Backup
+
+	edit := NewProposal(OpUpdate, "eve", originalEntries[0], &changed)
+	removal := NewProposal(OpDelete, "eve", originalEntries[1], nil)
+
+	for _, proposal := range []*Proposal{edit, removal} {
+		if err := ds.AddProposal(proposal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := So(removal.ID, ShouldBeGreaterThan, edit.ID); !ok {
+		t.Error(err)
+	}
+
+	reviewed, err := ds.ReviewProposal(removal.ID, ProposalRejected, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(reviewed.Status, ShouldEqual, ProposalRejected); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(reviewed.Reviewer, ShouldEqual, "alice"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(reviewed.ReviewedAt, ShouldNotBeNil); !ok {
+		t.Error(err)
+	}
+
+	if _, err = ds.ReviewProposal(removal.ID, ProposalApproved, "bob"); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected %v, got %v", ErrNotPending, err)
+	}
+
+	if _, err = ds.ReviewProposal(removal.ID+100, ProposalApproved, "bob"); !errors.Is(err, ErrNoProposal) {
+		t.Errorf("expected %v, got %v", ErrNoProposal, err)
+	}
+
+	pending, err := ds.ListProposals(ProposalPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(pending, ShouldHaveLength, 1); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(pending[0].ProposedAt, ShouldHappenWithin, time.Millisecond, edit.ProposedAt); !ok {
+		t.Error(err)
+	}
+
+	pending[0].ProposedAt = edit.ProposedAt
+
+	if ok, err := So(pending[0], ShouldResemble, edit); !ok {
+		t.Error(err)
+	}
+
+	stored, err := ds.GetProposal(removal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored.Before, ShouldResemble, originalEntries[1]); !ok {
+		t.Error(err)
+	}
+
+	if err = ds.ReopenProposal(removal.ID); !errors.Is(err, ErrNotPending) {
+		t.Errorf("expected %v, got %v", ErrNotPending, err)
+	}
+
+	if _, err = ds.ReviewProposal(edit.ID, ProposalApproved, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ds.ReopenProposal(edit.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if stored, err = ds.GetProposal(edit.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored.Status, ShouldEqual, ProposalPending); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(stored.ReviewedAt, ShouldBeNil); !ok {
+		t.Error(err)
+	}
+}
+
 func testDataSourceAddEntry(t *testing.T, ds DataSource, originalEntries []*Entry) {
 	newEntry := originalEntries[0]
 	newEntry.ReportingName = "test_project_new"
@@ -138,3 +432,218 @@ func testDataSourceAddEntry(t *testing.T, ds DataSource, originalEntries []*Entr
 		t.Error(err)
 	}
 }
+
+func testDataSourceHistory(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	updated := *originalEntries[0]
+	updated.Instruction = NoBackup
+
+	records := []*HistoryRecord{
+		NewHistoryRecord(OpAdd, "user_a", nil, originalEntries[0]),
+		NewHistoryRecord(OpAdd, "user_a", nil, originalEntries[1]),
+		NewHistoryRecord(OpUpdate, "user_b", originalEntries[0], &updated),
+		NewHistoryRecord(OpDelete, "user_c", &updated, nil),
+	}
+
+	for _, record := range records {
+		record.Timestamp = record.Timestamp.Truncate(time.Microsecond)
+
+		if err := ds.AddHistory(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := ds.GetHistory(originalEntries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*HistoryRecord{records[0], records[2], records[3]}
+
+	if ok, err := So(history, ShouldResemble, expected); !ok {
+		t.Error(err)
+	}
+
+	history, err = ds.GetHistory(originalEntries[len(originalEntries)-1].ID + 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(history, ShouldBeEmpty); !ok {
+		t.Error(err)
+	}
+}
+
+func testDataSourceQuery(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	first, second, third := *originalEntries[0], *originalEntries[1], *originalEntries[2]
+
+	first.Faculty = "Faculty_A"
+	first.Instruction = NoBackup
+	second.ReportingRoot = "/lustre/scratch125/humgen/projects/x"
+	second.Directory = "/lustre/scratch125/humgen/projects/x/input"
+	second.Match = "*.cram 100%_done"
+	third.Requestor = "zz_user"
+	third.Faculty = "faculty_a"
+
+	for _, entry := range []*Entry{&first, &second, &third} {
+		if err := ds.UpdateEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected []*Entry
+	}{
+		{"No filter returns everything by ID", Filter{}, []*Entry{&first, &second, &third}},
+		{"Descending order", Filter{Descending: true}, []*Entry{&third, &second, &first}},
+		{"Search is case insensitive", Filter{Search: "SCRATCH125"}, []*Entry{&second}},
+		{"Search wildcards match literally", Filter{Search: "100%_"}, []*Entry{&second}},
+		{"A lone wildcard matches literally", Filter{Search: "%"}, []*Entry{&second}},
+		{"Instruction", Filter{Instruction: NoBackup}, []*Entry{&first}},
+		{"Faculty ignores case", Filter{Faculty: "faculty_a"}, []*Entry{&first, &third}},
+		{"Requestor", Filter{Requestor: "zz_user"}, []*Entry{&third}},
+		{"Reporting root prefix", Filter{ReportingRootPrefix: "/lustre/"}, []*Entry{&second}},
+		{"Reporting root prefix matches case", Filter{ReportingRootPrefix: "/LUSTRE/"}, nil},
+		{"Reporting root prefix wildcards match literally", Filter{ReportingRootPrefix: "/lustre/*"}, nil},
+		{"Reporting root prefix LIKE wildcards match literally", Filter{ReportingRootPrefix: "/lustre_"}, nil},
+		{"Filters are combined", Filter{Faculty: "faculty_a", Instruction: Backup}, []*Entry{&third}},
+		{"Sort by column", Filter{SortBy: "requestor", Descending: true}, []*Entry{&third, &second, &first}},
+		{"Sort ties are broken by ID", Filter{SortBy: "faculty"}, []*Entry{&first, &third, &second}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ds.Query(&Query{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(page.Entries, ShouldResemble, tt.expected); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(page.Total, ShouldEqual, len(tt.expected)); !ok {
+				t.Error(err)
+			}
+		})
+	}
+
+	pageTestCases := []struct {
+		name     string
+		query    Query
+		expected []*Entry
+		total    int
+	}{
+		{"Limit", Query{Limit: 2}, []*Entry{&first, &second}, 3},
+		{"Offset", Query{Offset: 1}, []*Entry{&second, &third}, 3},
+		{"Limit and offset", Query{Limit: 1, Offset: 1}, []*Entry{&second}, 3},
+		{"Offset past the end", Query{Limit: 2, Offset: 5}, nil, 3},
+		{"Pages of filtered entries", Query{Filter: Filter{Faculty: "faculty_a", Descending: true}, Limit: 1},
+			[]*Entry{&third}, 2},
+	}
+
+	for _, tt := range pageTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := ds.Query(&tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(page.Entries, ShouldResemble, tt.expected); !ok {
+				t.Error(err)
+			}
+
+			if ok, err := So(page.Total, ShouldEqual, tt.total); !ok {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("Unknown sort columns are rejected", func(t *testing.T) {
+		_, err := ds.Query(&Query{Filter: Filter{SortBy: "id; DROP TABLE entries"}})
+		if !errors.Is(err, ErrInvalidSortColumn) {
+			t.Errorf("expected %v, got %v", ErrInvalidSortColumn, err)
+		}
+	})
+
+	t.Run("Negative offsets are rejected", func(t *testing.T) {
+		_, err := ds.Query(&Query{Offset: -1})
+		if !errors.Is(err, ErrInvalidPage) {
+			t.Errorf("expected %v, got %v", ErrInvalidPage, err)
+		}
+	})
+}
+
+func testDataSourceSnapshots(t *testing.T, ds DataSource, originalEntries []*Entry) {
+	t.Helper()
+
+	if _, err := ds.GetSnapshot(1); !errors.Is(err, ErrNoSnapshot) {
+		t.Fatalf("expected %v, got %v", ErrNoSnapshot, err)
+	}
+
+	first, err := ds.PublishSnapshot("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(first.Number, ShouldEqual, 1); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(first.Entries, ShouldResemble, originalEntries); !ok {
+		t.Error(err)
+	}
+
+	if _, err = ds.DeleteEntry(originalEntries[1].ID, originalEntries[1].Version); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ds.PublishSnapshot("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(second.Number, ShouldEqual, 2); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(second.EntryCount, ShouldEqual, len(originalEntries)-1); !ok {
+		t.Error(err)
+	}
+
+	snapshots, err := ds.ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(snapshots, ShouldHaveLength, 2); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(snapshots[0].PublishedBy, ShouldEqual, "alice"); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(snapshots[0].Entries, ShouldBeNil); !ok {
+		t.Error(err)
+	}
+
+	// later changes to the plan do not change published snapshots
+	stored, err := ds.GetSnapshot(first.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored.PublishedAt, ShouldHappenWithin, time.Millisecond, first.PublishedAt); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(stored.Entries, ShouldResemble, originalEntries); !ok {
+		t.Error(err)
+	}
+}
@@ -2,17 +2,23 @@ package sources
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLSource struct {
 	db        *sql.DB
 	tableName string
+	dialect   dialect
 }
 
 type SQLiteSource struct {
@@ -23,34 +29,48 @@ type MySQLSource struct {
 	*SQLSource
 }
 
+type PostgresSource struct {
+	*SQLSource
+}
+
 const DefaultTableName = "entries"
 
-const createTableTmpl = `CREATE TABLE IF NOT EXISTS %s (
-	id INTEGER PRIMARY KEY %s,
-	reporting_name TEXT,
-	reporting_root TEXT,
-	directory TEXT,
-	instruction TEXT CHECK ( instruction IN ('%s', '%s', '%s') ),
-	keep TEXT,
-	skip TEXT,
-	requestor TEXT,
-	faculty TEXT
-)`
+const (
+	historyTableSuffix         = "_history"
+	proposalsTableSuffix       = "_proposals"
+	snapshotsTableSuffix       = "_snapshots"
+	snapshotEntriesTableSuffix = "_snapshot_entries"
+)
 
 const (
-	getAllStmt          = "SELECT * FROM %s"
-	getEntryStmt        = "SELECT * FROM %s WHERE id = ?"
-	deleteEntryStmt     = "DELETE FROM %s WHERE id = ?"
-	deleteReturningStmt = "DELETE FROM %s WHERE id = ? RETURNING *"
-	updateEntryStmt     = `UPDATE %s 
-					   SET reporting_name = ?, reporting_root = ?, directory = ?, instruction = ?, 
-                       keep = ?, skip = ?, requestor = ?, faculty = ? WHERE id = ?`
-	insertEntryStmt = `INSERT INTO %s 
-			          (reporting_name, reporting_root, directory, instruction, keep, skip, requestor, faculty) 
-			          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	countStmt         = "SELECT COUNT(*) FROM %s"
+	returningIDClause = " RETURNING id"
+	addVersionStmt    = "ALTER TABLE %s ADD COLUMN version INTEGER NOT NULL DEFAULT 0"
+	insertHistoryStmt = `INSERT INTO %s 
+			          (entry_id, operation, actor, changed_at, before_entry, after_entry) 
+			          VALUES (?, ?, ?, ?, ?, ?)`
+	getHistoryStmt = `SELECT entry_id, operation, actor, changed_at, before_entry, after_entry 
+			          FROM %s WHERE entry_id = ? ORDER BY id`
+	insertProposalStmt = `INSERT INTO %s
+			          (operation, proposer, proposed_at, before_entry, after_entry, status)
+			          VALUES (?, ?, ?, ?, ?, ?)`
+	selectProposalsStmt = `SELECT id, operation, proposer, proposed_at, before_entry, after_entry, status,
+			          reviewer, reviewed_at FROM %s`
+	listProposalsStmt   = selectProposalsStmt + " WHERE status = ? ORDER BY id"
+	getProposalStmt     = selectProposalsStmt + " WHERE id = ?"
+	reviewProposalStmt  = "UPDATE %s SET status = ?, reviewer = ?, reviewed_at = ? WHERE id = ? AND status = ?"
+	reopenProposalStmt  = "UPDATE %s SET status = ?, reviewer = NULL, reviewed_at = NULL WHERE id = ? AND status = ?"
+	insertSnapshotStmt  = "INSERT INTO %s (published_by, published_at, entry_count) VALUES (?, ?, ?)"
+	selectSnapshotsStmt = "SELECT id, published_by, published_at, entry_count FROM %s"
+	listSnapshotsStmt   = selectSnapshotsStmt + " ORDER BY id"
+	getSnapshotStmt     = selectSnapshotsStmt + " WHERE id = ?"
+	countSnapshotStmt   = "UPDATE %s SET entry_count = ? WHERE id = ?"
 )
 
-var ErrMissingArgument = errors.New("missing required argument")
+var (
+	ErrMissingArgument = errors.New("missing required argument")
+	ErrInvalidID       = errors.New("database generated an invalid entry ID")
+)
 
 func (sq SQLSource) callAndLogError(f func() error) {
 	err := f()
@@ -64,7 +84,7 @@ func (sq SQLSource) callAndLogError(f func() error) {
 func NewSQLiteSource(path string) (SQLiteSource, error) {
 	db, err := sql.Open("sqlite3", path)
 
-	return SQLiteSource{&SQLSource{db: db, tableName: DefaultTableName}}, err
+	return SQLiteSource{&SQLSource{db: db, tableName: DefaultTableName, dialect: sqliteDialect}}, err
 }
 
 // NewMySQLSource opens a connection to a MySQL database using given credentials and stores it internally.
@@ -94,30 +114,116 @@ func NewMySQLSource(host, port, user, password, dbName, tableName string) (MySQL
 
 	db, err := sql.Open("mysql", address)
 
-	return MySQLSource{&SQLSource{db: db, tableName: tableName}}, err
+	return MySQLSource{&SQLSource{db: db, tableName: tableName, dialect: mysqlDialect}}, err
+}
+
+// NewPostgresSource opens a connection to a PostgreSQL database using given credentials and stores it internally.
+// Connection settings not given here, such as PGSSLMODE, are taken from the standard PG* environment variables.
+// You are responsible to close the connection using Close().
+func NewPostgresSource(host, port, user, password, dbName, tableName string) (PostgresSource, error) {
+	var missing []string
+	if host == "" {
+		missing = append(missing, "host")
+	}
+	if port == "" {
+		missing = append(missing, "port")
+	}
+	if user == "" {
+		missing = append(missing, "user")
+	}
+	if dbName == "" {
+		missing = append(missing, "dbName")
+	}
+	if len(missing) > 0 {
+		return PostgresSource{}, fmt.Errorf("%w: %v\n", ErrMissingArgument, missing)
+	}
+
+	address := url.URL{
+		Scheme: "postgres",
+		User:   url.User(user),
+		Host:   net.JoinHostPort(host, port),
+		Path:   dbName,
+	}
+
+	if password != "" {
+		address.User = url.UserPassword(user, password)
+	}
+
+	db, err := sql.Open("postgres", address.String())
+
+	return PostgresSource{&SQLSource{db: db, tableName: tableName, dialect: postgresDialect}}, err
 }
 
 func (sq SQLSource) Close() error {
 	return sq.db.Close()
 }
 
-func (sq SQLiteSource) CreateTable() error {
-	return sq.createTable("AUTOINCREMENT")
+// exec, query and queryRow run the statement on the database, after rewriting
+// its placeholders for the dialect.
+func (sq SQLSource) exec(stmt string, args ...any) (sql.Result, error) {
+	return sq.db.Exec(sq.dialect.rebind(stmt), args...)
 }
 
-func (sq SQLSource) createTable(incrementTerm string) error {
-	createTableStmt := fmt.Sprintf(createTableTmpl, sq.tableName, incrementTerm, Backup, NoBackup, TempBackup)
-	_, err := sq.db.Exec(createTableStmt)
+func (sq SQLSource) query(stmt string, args ...any) (*sql.Rows, error) {
+	return sq.db.Query(sq.dialect.rebind(stmt), args...)
+}
 
-	return err
+func (sq SQLSource) queryRow(stmt string, args ...any) *sql.Row {
+	return sq.db.QueryRow(sq.dialect.rebind(stmt), args...)
 }
 
-func (sq MySQLSource) CreateTable() error {
-	return sq.createTable("AUTO_INCREMENT")
+// CreateTable creates the entries table and the table holding its change
+// history, or migrates them to the latest schema if they already exist.
+func (sq SQLSource) CreateTable() error {
+	return sq.Migrate()
+}
+
+func (sq SQLSource) historyTableName() string {
+	return sq.tableName + historyTableSuffix
+}
+
+func (sq SQLSource) proposalsTableName() string {
+	return sq.tableName + proposalsTableSuffix
+}
+
+func (sq SQLSource) snapshotsTableName() string {
+	return sq.tableName + snapshotsTableSuffix
+}
+
+func (sq SQLSource) snapshotEntriesTableName() string {
+	return sq.tableName + snapshotEntriesTableSuffix
 }
 
 func (sq SQLSource) ReadAll() ([]*Entry, error) {
-	rows, err := sq.db.Query(fmt.Sprintf(getAllStmt, sq.tableName))
+	return sq.queryEntries(fmt.Sprintf(getLiveStmt, sq.tableName))
+}
+
+// Query selects, sorts and counts the entries in the database, so only the
+// requested page is read.
+func (sq SQLSource) Query(query *Query) (*Page, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	where, args := sq.dialect.filterToSQL(&query.Filter)
+
+	var page Page
+
+	err := sq.queryRow(fmt.Sprintf(countStmt, sq.tableName)+where, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := fmt.Sprintf(getAllStmt, sq.tableName) + where + sortToSQL(&query.Filter) + limitClause
+	args = append(args, limitToSQL(query.Limit), query.Offset)
+
+	page.Entries, err = sq.queryEntries(stmt, args...)
+
+	return &page, err
+}
+
+func (sq SQLSource) queryEntries(stmt string, args ...any) ([]*Entry, error) {
+	rows, err := sq.query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +241,7 @@ func (sq SQLSource) ReadAll() ([]*Entry, error) {
 		entries = append(entries, entry)
 	}
 
-	return entries, nil
+	return entries, rows.Err()
 }
 
 type scanner interface {
@@ -145,18 +251,20 @@ type scanner interface {
 func (sq SQLSource) scanEntry(row scanner) (*Entry, error) {
 	var entry Entry
 
-	err := row.Scan(&entry.ID, &entry.ReportingName, &entry.ReportingRoot, &entry.Directory,
-		&entry.Instruction, &entry.Match, &entry.Ignore, &entry.Requestor, &entry.Faculty)
+	err := row.Scan(columnFields(&entry, entryColumns)...)
 
 	return &entry, err
 }
 
-func (sq SQLSource) GetEntry(id uint16) (*Entry, error) {
+func (sq SQLSource) GetEntry(id uint64) (*Entry, error) {
 	stmt := fmt.Sprintf(getEntryStmt, sq.tableName)
 
-	row := sq.db.QueryRow(stmt, id)
+	row := sq.queryRow(stmt, id)
 
 	entry, err := sq.scanEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoEntry
+	}
 
 	return entry, err
 }
@@ -164,8 +272,9 @@ func (sq SQLSource) GetEntry(id uint16) (*Entry, error) {
 func (sq SQLSource) UpdateEntry(newEntry *Entry) error {
 	stmt := fmt.Sprintf(updateEntryStmt, sq.tableName)
 
-	r, err := sq.db.Exec(stmt, newEntry.ReportingName, newEntry.ReportingRoot, newEntry.Directory,
-		newEntry.Instruction, newEntry.Match, newEntry.Ignore, newEntry.Requestor, newEntry.Faculty, newEntry.ID)
+	args := append(columnFields(newEntry, editableColumns), newEntry.ID, newEntry.Version)
+
+	r, err := sq.exec(stmt, args...)
 
 	if err != nil {
 		return err
@@ -177,26 +286,52 @@ func (sq SQLSource) UpdateEntry(newEntry *Entry) error {
 	}
 
 	if count == 0 {
-		return ErrNoEntry
+		return sq.missingOrConflict(newEntry.ID)
 	}
 
+	newEntry.Version++
+	newEntry.DeletedAt = nil
+
 	return nil
 }
 
-func (sq SQLiteSource) DeleteEntry(id uint16) (*Entry, error) {
+// missingOrConflict determines why a change to the entry with the given ID and
+// an expected version did not affect any rows.
+func (sq SQLSource) missingOrConflict(id uint64) error {
+	_, err := sq.GetEntry(id)
+	if err != nil {
+		return err
+	}
+
+	return ErrVersionConflict
+}
+
+// DeleteEntry marks the entry with the given ID deleted if it has the given
+// version, returning the entry as it was before.
+func (sq SQLSource) DeleteEntry(id uint64, version uint32) (*Entry, error) {
+	deletedAt := formatDeletedAt(time.Now())
+
+	if !sq.dialect.returning {
+		return sq.deleteEntryInTx(id, version, deletedAt)
+	}
+
 	stmt := fmt.Sprintf(deleteReturningStmt, sq.tableName)
 
-	row := sq.db.QueryRow(stmt, id)
+	row := sq.queryRow(stmt, deletedAt, id, version)
 
 	entry, err := sq.scanEntry(row)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrNoEntry
+		return nil, sq.missingOrConflict(id)
+	} else if err != nil {
+		return nil, err
 	}
 
-	return entry, err
+	return entry.undeleted(), nil
 }
 
-func (sq MySQLSource) DeleteEntry(id uint16) (*Entry, error) {
+// deleteEntryInTx reads and deletes the entry in a transaction, for databases
+// that cannot return the changed row.
+func (sq SQLSource) deleteEntryInTx(id uint64, version uint32, deletedAt string) (entry *Entry, err error) {
 	tx, err := sq.db.Begin()
 	if err != nil {
 		return nil, err
@@ -211,9 +346,9 @@ func (sq MySQLSource) DeleteEntry(id uint16) (*Entry, error) {
 	}()
 
 	getStmt := fmt.Sprintf(getEntryStmt, sq.tableName)
-	row := tx.QueryRow(getStmt, id)
+	row := tx.QueryRow(sq.dialect.rebind(getStmt), id)
 
-	entry, err := sq.scanEntry(row)
+	entry, err = sq.scanEntry(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoEntry
@@ -222,21 +357,60 @@ func (sq MySQLSource) DeleteEntry(id uint16) (*Entry, error) {
 		return nil, err
 	}
 
+	if entry.Version != version {
+		return nil, ErrVersionConflict
+	}
+
 	delStmt := fmt.Sprintf(deleteEntryStmt, sq.tableName)
 
-	_, err = tx.Exec(delStmt, id)
+	if _, err = tx.Exec(sq.dialect.rebind(delStmt), deletedAt, id, version); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (sq SQLSource) ListDeleted() ([]*Entry, error) {
+	return sq.queryEntries(fmt.Sprintf(getDeletedStmt, sq.tableName))
+}
+
+func (sq SQLSource) RestoreEntry(id uint64) (*Entry, error) {
+	r, err := sq.exec(fmt.Sprintf(restoreEntryStmt, sq.tableName), id)
 	if err != nil {
 		return nil, err
 	}
 
+	count, err := r.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := sq.GetEntry(id)
+	if count == 0 && err == nil {
+		return nil, ErrNotDeleted
+	}
+
 	return entry, err
 }
 
+func (sq SQLSource) PurgeDeleted(before time.Time) (int, error) {
+	r, err := sq.exec(fmt.Sprintf(purgeDeletedStmt, sq.tableName), formatDeletedAt(before))
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := r.RowsAffected()
+
+	return int(count), err
+}
+
 func (sq SQLSource) AddEntry(entry *Entry) error {
+	entry.DeletedAt = nil
+
 	return sq.WriteEntries([]*Entry{entry})
 }
 
-func (sq SQLSource) WriteEntries(entries []*Entry) error {
+func (sq SQLSource) WriteEntries(entries []*Entry) (err error) {
 	tx, err := sq.db.Begin()
 	if err != nil {
 		return err
@@ -250,42 +424,163 @@ func (sq SQLSource) WriteEntries(entries []*Entry) error {
 		}
 	}()
 
-	stmt, err := tx.Prepare(fmt.Sprintf(insertEntryStmt, sq.tableName))
+	return sq.insertEntriesInTx(tx, entries)
+}
+
+// insertEntriesInTx adds the entries in the transaction, giving them the IDs
+// the database chose.
+func (sq SQLSource) insertEntriesInTx(tx *sql.Tx, entries []*Entry) error {
+	insertStmt := fmt.Sprintf(insertEntryStmt, sq.tableName)
+	if sq.dialect.returning {
+		insertStmt += returningIDClause
+	}
+
+	stmt, err := tx.Prepare(sq.dialect.rebind(insertStmt))
 	if err != nil {
 		return err
 	}
 	defer sq.callAndLogError(stmt.Close)
 
 	for _, entry := range entries {
-		r, err := stmt.Exec(entry.ReportingName, entry.ReportingRoot, entry.Directory,
-			entry.Instruction, entry.Match, entry.Ignore, entry.Requestor, entry.Faculty)
+		args := columnFields(entry, insertColumns)
+
+		var id int64
+
+		if sq.dialect.returning {
+			err = stmt.QueryRow(args...).Scan(&id)
+		} else {
+			id, err = sq.insertAndGetID(stmt, args)
+		}
 
 		if err != nil {
 			return err
 		}
 
-		id, err := r.LastInsertId()
-		if err != nil {
+		if id < 0 {
+			err = fmt.Errorf("%w: %d", ErrInvalidID, id)
+
 			return err
 		}
 
-		entry.ID = uint16(id)
+		entry.ID = uint64(id)
 	}
 
 	return err
 }
 
+// ApplyChanges makes every change in a single transaction, rolled back if any
+// change cannot be made.
+func (sq SQLSource) ApplyChanges(changes *ChangeSet) (err error) {
+	tx, err := sq.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			sq.callAndLogError(tx.Rollback)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	updateStmt := sq.dialect.rebind(fmt.Sprintf(updateEntryStmt, sq.tableName))
+
+	for _, entry := range changes.Update {
+		args := append(columnFields(entry, editableColumns), entry.ID, entry.Version)
+
+		if err = sq.changeOneInTx(tx, entry.ID, updateStmt, args...); err != nil {
+			return err
+		}
+	}
+
+	deleteStmt := sq.dialect.rebind(fmt.Sprintf(deleteEntryStmt, sq.tableName))
+	deletedAt := formatDeletedAt(time.Now())
+
+	for _, entry := range changes.Delete {
+		if err = sq.changeOneInTx(tx, entry.ID, deleteStmt, deletedAt, entry.ID, entry.Version); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range changes.Add {
+		entry.DeletedAt = nil
+	}
+
+	if err = sq.insertEntriesInTx(tx, changes.Add); err != nil {
+		return err
+	}
+
+	for _, entry := range changes.Update {
+		entry.Version++
+		entry.DeletedAt = nil
+	}
+
+	return nil
+}
+
+// changeOneInTx runs a statement changing the entry with the given ID in the
+// transaction, returning an error naming the entry if it changed no rows.
+func (sq SQLSource) changeOneInTx(tx *sql.Tx, id uint64, stmt string, args ...any) error {
+	r, err := tx.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	err = ErrVersionConflict
+
+	getStmt := fmt.Sprintf(getEntryStmt, sq.tableName)
+	if _, scanErr := sq.scanEntry(tx.QueryRow(sq.dialect.rebind(getStmt), id)); errors.Is(scanErr, sql.ErrNoRows) {
+		err = ErrNoEntry
+	} else if scanErr != nil {
+		err = scanErr
+	}
+
+	return fmt.Errorf("entry %d: %w", id, err)
+}
+
+func (sq SQLSource) insertAndGetID(stmt *sql.Stmt, args []any) (int64, error) {
+	r, err := stmt.Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.LastInsertId()
+}
+
+// DropTable drops the entries table, and forgets its schema version so
+// CreateTable can create it again. The history, proposals and snapshots kept
+// alongside it are left alone, as the migrations creating them keep them too.
 func (sq SQLSource) DropTable() error {
-	_, err := sq.db.Exec(fmt.Sprintf("DROP TABLE %s", sq.tableName))
+	_, err := sq.exec(fmt.Sprintf("DROP TABLE %s", sq.tableName))
+	if err != nil {
+		return err
+	}
+
+	if _, err = sq.exec(createMigrationsTableStmt); err != nil {
+		return err
+	}
+
+	_, err = sq.exec(forgetMigrationsStmt, sq.tableName)
+
 	return err
 }
 
-func (sq MySQLSource) ShowTables() ([]string, error) {
-	return sq.scanTableNames("SHOW TABLES")
+func (sq SQLSource) ShowTables() ([]string, error) {
+	return sq.scanTableNames(sq.dialect.showTablesStmt)
 }
 
 func (sq SQLSource) scanTableNames(stmt string) ([]string, error) {
-	rows, err := sq.db.Query(stmt)
+	rows, err := sq.query(stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -306,6 +601,385 @@ func (sq SQLSource) scanTableNames(stmt string) ([]string, error) {
 	return tableNames, nil
 }
 
-func (sq SQLiteSource) ShowTables() ([]string, error) {
-	return sq.scanTableNames("SELECT name FROM sqlite_master WHERE type='table'")
+func (sq SQLSource) AddHistory(record *HistoryRecord) error {
+	before, err := marshalNullableEntry(record.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := marshalNullableEntry(record.After)
+	if err != nil {
+		return err
+	}
+
+	stmt := fmt.Sprintf(insertHistoryStmt, sq.historyTableName())
+
+	_, err = sq.exec(stmt, record.EntryID, record.Operation, record.Actor,
+		record.Timestamp.UTC().Format(time.RFC3339Nano), before, after)
+
+	return err
+}
+
+func marshalNullableEntry(entry *Entry) (sql.NullString, error) {
+	if entry == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(entry)
+
+	return sql.NullString{String: string(b), Valid: true}, err
+}
+
+func (sq SQLSource) GetHistory(entryID uint64) ([]*HistoryRecord, error) {
+	rows, err := sq.query(fmt.Sprintf(getHistoryStmt, sq.historyTableName()), entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer sq.callAndLogError(rows.Close)
+
+	var records []*HistoryRecord
+
+	for rows.Next() {
+		record, err := sq.scanHistoryRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (sq SQLSource) scanHistoryRecord(row scanner) (*HistoryRecord, error) {
+	var (
+		record        HistoryRecord
+		timestamp     string
+		before, after sql.NullString
+	)
+
+	err := row.Scan(&record.EntryID, &record.Operation, &record.Actor, &timestamp, &before, &after)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Before, err = unmarshalNullableEntry(before); err != nil {
+		return nil, err
+	}
+
+	record.After, err = unmarshalNullableEntry(after)
+
+	return &record, err
+}
+
+func unmarshalNullableEntry(s sql.NullString) (*Entry, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	var entry Entry
+
+	err := json.Unmarshal([]byte(s.String), &entry)
+
+	return &entry, err
+}
+
+func (sq SQLSource) AddProposal(proposal *Proposal) error {
+	before, err := marshalNullableEntry(proposal.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := marshalNullableEntry(proposal.After)
+	if err != nil {
+		return err
+	}
+
+	stmt := fmt.Sprintf(insertProposalStmt, sq.proposalsTableName())
+	args := []any{proposal.Operation, proposal.Proposer, proposal.ProposedAt.UTC().Format(time.RFC3339Nano),
+		before, after, proposal.Status}
+
+	var id int64
+
+	if sq.dialect.returning {
+		err = sq.queryRow(stmt+returningIDClause, args...).Scan(&id)
+	} else {
+		var r sql.Result
+
+		if r, err = sq.exec(stmt, args...); err == nil {
+			id, err = r.LastInsertId()
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if id < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidID, id)
+	}
+
+	proposal.ID = uint64(id)
+
+	return nil
+}
+
+func (sq SQLSource) ListProposals(status ProposalStatus) ([]*Proposal, error) {
+	rows, err := sq.query(fmt.Sprintf(listProposalsStmt, sq.proposalsTableName()), status)
+	if err != nil {
+		return nil, err
+	}
+
+	defer sq.callAndLogError(rows.Close)
+
+	var proposals []*Proposal
+
+	for rows.Next() {
+		proposal, err := scanProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		proposals = append(proposals, proposal)
+	}
+
+	return proposals, rows.Err()
+}
+
+func (sq SQLSource) GetProposal(id uint64) (*Proposal, error) {
+	proposal, err := scanProposal(sq.queryRow(fmt.Sprintf(getProposalStmt, sq.proposalsTableName()), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoProposal
+	}
+
+	return proposal, err
+}
+
+// ReviewProposal only updates the proposal while it is pending, so that only one
+// reviewer can decide on it.
+func (sq SQLSource) ReviewProposal(id uint64, status ProposalStatus, reviewer string) (*Proposal, error) {
+	stmt := fmt.Sprintf(reviewProposalStmt, sq.proposalsTableName())
+
+	r, err := sq.exec(stmt, status, reviewer, time.Now().UTC().Format(time.RFC3339Nano), id, ProposalPending)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := sq.GetProposal(id)
+	if count == 0 && err == nil {
+		return nil, ErrNotPending
+	}
+
+	return proposal, err
+}
+
+func (sq SQLSource) ReopenProposal(id uint64) error {
+	stmt := fmt.Sprintf(reopenProposalStmt, sq.proposalsTableName())
+
+	r, err := sq.exec(stmt, ProposalPending, id, ProposalApproved)
+	if err != nil {
+		return err
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	if _, err = sq.GetProposal(id); err != nil {
+		return err
+	}
+
+	return ErrNotPending
+}
+
+func scanProposal(row scanner) (*Proposal, error) {
+	var (
+		proposal             Proposal
+		proposedAt           string
+		before, after        sql.NullString
+		reviewer, reviewedAt sql.NullString
+	)
+
+	err := row.Scan(&proposal.ID, &proposal.Operation, &proposal.Proposer, &proposedAt, &before, &after,
+		&proposal.Status, &reviewer, &reviewedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if proposal.ProposedAt, err = time.Parse(time.RFC3339Nano, proposedAt); err != nil {
+		return nil, err
+	}
+
+	if reviewedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, reviewedAt.String)
+		if err != nil {
+			return nil, err
+		}
+
+		proposal.ReviewedAt = &t
+	}
+
+	proposal.Reviewer = reviewer.String
+
+	if proposal.Before, err = unmarshalNullableEntry(before); err != nil {
+		return nil, err
+	}
+
+	proposal.After, err = unmarshalNullableEntry(after)
+
+	return &proposal, err
+}
+
+// PublishSnapshot copies the live entries in the same transaction as it numbers
+// the snapshot, so the snapshot is the plan at a single moment.
+func (sq SQLSource) PublishSnapshot(publisher string) (snapshot *Snapshot, err error) {
+	tx, err := sq.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			sq.callAndLogError(tx.Rollback)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	snapshot = newSnapshot(publisher, nil)
+
+	stmt := fmt.Sprintf(insertSnapshotStmt, sq.snapshotsTableName())
+	args := []any{snapshot.PublishedBy, snapshot.PublishedAt.Format(time.RFC3339Nano), 0}
+
+	var number int64
+
+	if sq.dialect.returning {
+		err = tx.QueryRow(sq.dialect.rebind(stmt+returningIDClause), args...).Scan(&number)
+	} else {
+		var r sql.Result
+
+		if r, err = tx.Exec(sq.dialect.rebind(stmt), args...); err == nil {
+			number, err = r.LastInsertId()
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if number < 0 {
+		err = fmt.Errorf("%w: %d", ErrInvalidID, number)
+
+		return nil, err
+	}
+
+	snapshot.Number = uint64(number)
+
+	stmt = fmt.Sprintf(copySnapshotEntriesStmt, sq.snapshotEntriesTableName(), sq.tableName)
+	if _, err = tx.Exec(sq.dialect.rebind(stmt), snapshot.Number); err != nil {
+		return nil, err
+	}
+
+	if snapshot.Entries, err = sq.querySnapshotEntries(tx, snapshot.Number); err != nil {
+		return nil, err
+	}
+
+	snapshot.EntryCount = len(snapshot.Entries)
+
+	stmt = fmt.Sprintf(countSnapshotStmt, sq.snapshotsTableName())
+	_, err = tx.Exec(sq.dialect.rebind(stmt), snapshot.EntryCount, snapshot.Number)
+
+	return snapshot, err
+}
+
+// querier is a database or a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func (sq SQLSource) querySnapshotEntries(q querier, number uint64) ([]*Entry, error) {
+	rows, err := q.Query(sq.dialect.rebind(fmt.Sprintf(getSnapshotEntriesStmt, sq.snapshotEntriesTableName())),
+		number)
+	if err != nil {
+		return nil, err
+	}
+
+	defer sq.callAndLogError(rows.Close)
+
+	entries := []*Entry{}
+
+	for rows.Next() {
+		var entry Entry
+
+		if err = rows.Scan(columnFields(&entry, snapshotColumns)...); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (sq SQLSource) ListSnapshots() ([]*Snapshot, error) {
+	rows, err := sq.query(fmt.Sprintf(listSnapshotsStmt, sq.snapshotsTableName()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer sq.callAndLogError(rows.Close)
+
+	var snapshots []*Snapshot
+
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+func (sq SQLSource) GetSnapshot(number uint64) (*Snapshot, error) {
+	snapshot, err := scanSnapshot(sq.queryRow(fmt.Sprintf(getSnapshotStmt, sq.snapshotsTableName()), number))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSnapshot
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshot.Entries, err = sq.querySnapshotEntries(sq.db, number)
+
+	return snapshot, err
+}
+
+func scanSnapshot(row scanner) (*Snapshot, error) {
+	var (
+		snapshot    Snapshot
+		publishedAt string
+	)
+
+	err := row.Scan(&snapshot.Number, &snapshot.PublishedBy, &publishedAt, &snapshot.EntryCount)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.PublishedAt, err = time.Parse(time.RFC3339Nano, publishedAt)
+
+	return &snapshot, err
 }
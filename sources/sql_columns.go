@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// entryColumn maps a column of the entries table to the Entry field holding
+// its value.
+type entryColumn struct {
+	name  string
+	field func(e *Entry) any
+}
+
+// editableColumns are the columns holding the fields of an entry that users
+// can change, in the order they are inserted and updated.
+var editableColumns = []entryColumn{
+	{"reporting_name", func(e *Entry) any { return &e.ReportingName }},
+	{"reporting_root", func(e *Entry) any { return &e.ReportingRoot }},
+	{"directory", func(e *Entry) any { return &e.Directory }},
+	{"instruction", func(e *Entry) any { return &e.Instruction }},
+	{`"match"`, func(e *Entry) any { return &e.Match }},
+	{`"ignore"`, func(e *Entry) any { return &e.Ignore }},
+	{"requestor", func(e *Entry) any { return &e.Requestor }},
+	{"faculty", func(e *Entry) any { return &e.Faculty }},
+}
+
+var (
+	idColumn      = entryColumn{"id", func(e *Entry) any { return &e.ID }}
+	versionColumn = entryColumn{"version", func(e *Entry) any { return &e.Version }}
+	deletedColumn = entryColumn{"deleted_at", func(e *Entry) any { return deletedAtField{e} }}
+
+	// entryColumns are the columns read into an Entry. Any other columns in the
+	// table are ignored.
+	entryColumns = concatColumns([]entryColumn{idColumn}, editableColumns,
+		[]entryColumn{versionColumn, deletedColumn})
+
+	insertColumns = concatColumns(editableColumns, []entryColumn{versionColumn})
+
+	// snapshotColumns are the columns of an entry copied into a snapshot.
+	snapshotColumns = concatColumns([]entryColumn{idColumn}, insertColumns)
+)
+
+var (
+	getAllStmt          = "SELECT " + columnList(entryColumns) + " FROM %s"
+	getLiveStmt         = getAllStmt + " WHERE " + liveCondition
+	getEntryStmt        = getLiveStmt + " AND id = ?"
+	getDeletedStmt      = getAllStmt + " WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id"
+	deleteEntryStmt     = "UPDATE %s SET deleted_at = ? WHERE id = ? AND version = ? AND " + liveCondition
+	deleteReturningStmt = deleteEntryStmt + " RETURNING " + columnList(entryColumns)
+	restoreEntryStmt    = "UPDATE %s SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"
+	purgeDeletedStmt    = "DELETE FROM %s WHERE deleted_at IS NOT NULL AND deleted_at < ?"
+	updateEntryStmt     = "UPDATE %s SET " + assignmentList(editableColumns) +
+		", version = version + 1 WHERE id = ? AND version = ? AND " + liveCondition
+	insertEntryStmt = "INSERT INTO %s (" + columnList(insertColumns) + ") VALUES (" +
+		placeholderList(len(insertColumns)) + ")"
+
+	// copySnapshotEntriesStmt copies the live entries of the entries table,
+	// the second placeholder, into the snapshot entries table, the first.
+	copySnapshotEntriesStmt = "INSERT INTO %s (snapshot_id, " + columnList(snapshotColumns) + ") SELECT ?, " +
+		columnList(snapshotColumns) + " FROM %s WHERE " + liveCondition
+	getSnapshotEntriesStmt = "SELECT " + columnList(snapshotColumns) + " FROM %s WHERE snapshot_id = ? ORDER BY id"
+)
+
+// liveCondition selects the entries that have not been deleted.
+const liveCondition = "deleted_at IS NULL"
+
+// deletedAtFormat is how deletion times are stored: always in UTC and with a
+// fixed number of digits, so they sort in time order as text.
+const deletedAtFormat = "2006-01-02T15:04:05.000000Z"
+
+// deletedAtField scans and stores the DeletedAt field of an entry, which is
+// NULL in the database for entries that have not been deleted.
+type deletedAtField struct {
+	entry *Entry
+}
+
+func (f deletedAtField) Scan(src any) error {
+	var s string
+
+	switch v := src.(type) {
+	case nil:
+		f.entry.DeletedAt = nil
+
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into deleted_at", src)
+	}
+
+	t, err := time.Parse(deletedAtFormat, s)
+	if err != nil {
+		return err
+	}
+
+	f.entry.DeletedAt = &t
+
+	return nil
+}
+
+func (f deletedAtField) Value() (driver.Value, error) {
+	if f.entry.DeletedAt == nil {
+		return nil, nil
+	}
+
+	return formatDeletedAt(*f.entry.DeletedAt), nil
+}
+
+func formatDeletedAt(t time.Time) string {
+	return t.UTC().Format(deletedAtFormat)
+}
+
+func concatColumns(columns ...[]entryColumn) []entryColumn {
+	var all []entryColumn
+
+	for _, c := range columns {
+		all = append(all, c...)
+	}
+
+	return all
+}
+
+func columnList(columns []entryColumn) string {
+	names := make([]string, len(columns))
+
+	for i, column := range columns {
+		names[i] = column.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func assignmentList(columns []entryColumn) string {
+	assignments := make([]string, len(columns))
+
+	for i, column := range columns {
+		assignments[i] = column.name + " = ?"
+	}
+
+	return strings.Join(assignments, ", ")
+}
+
+func placeholderList(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// columnFields returns pointers to the fields of the entry for the given
+// columns, to scan a row into or to use as statement arguments.
+func columnFields(entry *Entry, columns []entryColumn) []any {
+	fields := make([]any, len(columns))
+
+	for i, column := range columns {
+		fields[i] = column.field(entry)
+	}
+
+	return fields
+}
@@ -0,0 +1,114 @@
+package sources
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect describes how the SQL understood by a database differs from the
+// SQLite flavour the statements in this package are written in. Identifiers
+// that are reserved words, such as "match", are quoted with double quotes.
+type dialect struct {
+	// autoIncrement follows the definition of an integer primary key to have the
+	// database generate its values.
+	autoIncrement string
+
+	// numberedPlaceholders is true if the database expects $1, $2, ... in place
+	// of ?.
+	numberedPlaceholders bool
+
+	// identifierQuote replaces the double quotes around identifiers, if set.
+	identifierQuote rune
+
+	// returning is true if the database can return the rows changed by an
+	// INSERT or DELETE.
+	returning bool
+
+	// showTablesStmt selects the names of the tables in the database.
+	showTablesStmt string
+
+	// widenIDStmts change the ID columns of the entries and history tables to
+	// 64-bit integers, for databases where INTEGER is 32-bit.
+	widenIDStmts []string
+
+	// widenProposalIDStmts and widenSnapshotIDStmts do the same for the
+	// proposals table, and for the snapshot tables.
+	widenProposalIDStmts []string
+	widenSnapshotIDStmts []string
+
+	// globPrefixes is true if prefixes are matched with GLOB, and binaryLike true
+	// if they are matched by LIKE on binary strings, where LIKE ignores case.
+	globPrefixes bool
+	binaryLike   bool
+}
+
+var (
+	sqliteDialect = dialect{
+		autoIncrement:  "AUTOINCREMENT",
+		returning:      true,
+		showTablesStmt: "SELECT name FROM sqlite_master WHERE type='table'",
+		globPrefixes:   true,
+	}
+
+	mysqlDialect = dialect{
+		autoIncrement:   "AUTO_INCREMENT",
+		identifierQuote: '`',
+		showTablesStmt:  "SHOW TABLES",
+		binaryLike:      true,
+		widenIDStmts: []string{
+			"ALTER TABLE %[1]s MODIFY id BIGINT NOT NULL AUTO_INCREMENT",
+			"ALTER TABLE %[3]s MODIFY id BIGINT NOT NULL AUTO_INCREMENT, MODIFY entry_id BIGINT",
+		},
+		widenProposalIDStmts: []string{"ALTER TABLE %[7]s MODIFY id BIGINT NOT NULL AUTO_INCREMENT"},
+		widenSnapshotIDStmts: []string{
+			"ALTER TABLE %[8]s MODIFY id BIGINT NOT NULL AUTO_INCREMENT",
+			"ALTER TABLE %[9]s MODIFY snapshot_id BIGINT NOT NULL",
+		},
+	}
+
+	postgresDialect = dialect{
+		autoIncrement:        "GENERATED BY DEFAULT AS IDENTITY",
+		numberedPlaceholders: true,
+		returning:            true,
+		showTablesStmt:       "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = current_schema()",
+		widenIDStmts: []string{
+			"ALTER TABLE %[1]s ALTER COLUMN id TYPE BIGINT",
+			"ALTER TABLE %[3]s ALTER COLUMN id TYPE BIGINT, ALTER COLUMN entry_id TYPE BIGINT",
+		},
+		widenProposalIDStmts: []string{"ALTER TABLE %[7]s ALTER COLUMN id TYPE BIGINT"},
+		widenSnapshotIDStmts: []string{
+			"ALTER TABLE %[8]s ALTER COLUMN id TYPE BIGINT",
+			"ALTER TABLE %[9]s ALTER COLUMN snapshot_id TYPE BIGINT",
+		},
+	}
+)
+
+// rebind rewrites the ? placeholders and quoted identifiers in stmt into the
+// form the database expects. Statements must not contain ? or " other than as
+// placeholders and around identifiers.
+func (d dialect) rebind(stmt string) string {
+	if !d.numberedPlaceholders && d.identifierQuote == 0 {
+		return stmt
+	}
+
+	var (
+		sb strings.Builder
+		n  int
+	)
+
+	for _, r := range stmt {
+		switch {
+		case r == '?' && d.numberedPlaceholders:
+			n++
+
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+		case r == '"' && d.identifierQuote != 0:
+			sb.WriteRune(d.identifierQuote)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
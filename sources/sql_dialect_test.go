@@ -0,0 +1,31 @@
+package sources
+
+import (
+	"testing"
+
+	. "github.com/smarty/assertions"
+)
+
+func TestDialectRebind(t *testing.T) {
+	stmt := `SELECT * FROM entries WHERE id = ? AND "match" = ? LIMIT ?`
+
+	tests := []struct {
+		name     string
+		dialect  dialect
+		expected string
+	}{
+		{"SQLite keeps ? placeholders", sqliteDialect, stmt},
+		{"MySQL quotes identifiers with backticks", mysqlDialect,
+			"SELECT * FROM entries WHERE id = ? AND `match` = ? LIMIT ?"},
+		{"PostgreSQL numbers placeholders", postgresDialect,
+			`SELECT * FROM entries WHERE id = $1 AND "match" = $2 LIMIT $3`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok, err := So(tt.dialect.rebind(stmt), ShouldEqual, tt.expected); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
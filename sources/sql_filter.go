@@ -0,0 +1,125 @@
+package sources
+
+import (
+	"math"
+	"strings"
+)
+
+const limitClause = " LIMIT ? OFFSET ?"
+
+// sqlColumns maps the names entries can be sorted by to their database column.
+var sqlColumns = map[string]string{
+	"id":             "id",
+	"reporting_name": "reporting_name",
+	"reporting_root": "reporting_root",
+	"directory":      "directory",
+	"instruction":    "instruction",
+	"match":          `"match"`,
+	"ignore":         `"ignore"`,
+	"requestor":      "requestor",
+	"faculty":        "faculty",
+}
+
+// searchColumns are the database columns searched by Filter.Search.
+var searchColumns = []string{"reporting_name", "reporting_root", "directory", "instruction",
+	`"match"`, `"ignore"`, "requestor", "faculty"}
+
+const likeEscape = "!"
+
+// escapeLike escapes the wildcards in s so it matches literally in a LIKE
+// pattern using likeEscape as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%",
+		"_", likeEscape+"_").Replace(s)
+}
+
+func likeClause(column string) string {
+	return column + " LIKE ? ESCAPE '" + likeEscape + "'"
+}
+
+// escapeGlob escapes the wildcards in s so it matches literally in a GLOB
+// pattern.
+func escapeGlob(s string) string {
+	return strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(s)
+}
+
+// prefixCondition returns a condition, and its argument, selecting the rows
+// whose column starts with prefix, matching case as strings.HasPrefix does.
+func (d dialect) prefixCondition(column, prefix string) (string, any) {
+	switch {
+	case d.globPrefixes:
+		return column + " GLOB ?", escapeGlob(prefix) + "*"
+	case d.binaryLike:
+		return column + " LIKE CAST(? AS BINARY) ESCAPE '" + likeEscape + "'", escapeLike(prefix) + "%"
+	default:
+		return likeClause(column), escapeLike(prefix) + "%"
+	}
+}
+
+// filterToSQL returns a WHERE clause, and its arguments, selecting the entries
+// the filter matches that have not been deleted.
+func (d dialect) filterToSQL(f *Filter) (string, []any) {
+	var (
+		conditions = []string{liveCondition}
+		args       []any
+	)
+
+	if f.Instruction != "" {
+		conditions = append(conditions, "instruction = ?")
+		args = append(args, f.Instruction)
+	}
+
+	if f.Faculty != "" {
+		conditions = append(conditions, "LOWER(faculty) = LOWER(?)")
+		args = append(args, f.Faculty)
+	}
+
+	if f.Requestor != "" {
+		conditions = append(conditions, "LOWER(requestor) = LOWER(?)")
+		args = append(args, f.Requestor)
+	}
+
+	if f.ReportingRootPrefix != "" {
+		condition, arg := d.prefixCondition("reporting_root", f.ReportingRootPrefix)
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if f.Search != "" {
+		search := make([]string, len(searchColumns))
+
+		for i, column := range searchColumns {
+			search[i] = likeClause("LOWER(" + column + ")")
+			args = append(args, "%"+escapeLike(strings.ToLower(f.Search))+"%")
+		}
+
+		conditions = append(conditions, "("+strings.Join(search, " OR ")+")")
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// sortToSQL returns the ORDER BY clause for a validated filter.
+func sortToSQL(f *Filter) string {
+	direction := " ASC"
+	if f.Descending {
+		direction = " DESC"
+	}
+
+	column, ok := sqlColumns[f.SortBy]
+	if !ok || column == "id" {
+		return " ORDER BY id" + direction
+	}
+
+	return " ORDER BY " + column + direction + ", id" + direction
+}
+
+// limitToSQL converts a Query.Limit to the value of a LIMIT clause, using the
+// largest possible value when there is no limit, as an OFFSET requires a LIMIT.
+func limitToSQL(limit int) int64 {
+	if limit == 0 {
+		return math.MaxInt64
+	}
+
+	return int64(limit)
+}
@@ -2,6 +2,7 @@ package sources
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -16,6 +17,7 @@ var sqlTestCases = []struct {
 }{
 	{"SQLite", setupSQLiteSourceForTest},
 	{"MySQL", setupMySQLSourceForTest},
+	{"PostgreSQL", setupPostgresSourceForTest},
 }
 
 func TestSQLSource_ReadAll(t *testing.T) {
@@ -28,6 +30,16 @@ func TestSQLSource_ReadAll(t *testing.T) {
 	}
 }
 
+func TestSQLSource_Query(t *testing.T) {
+	for _, tt := range sqlTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, sq := tt.src(t)
+
+			testDataSourceQuery(t, sq, entries)
+		})
+	}
+}
+
 func TestSQLSource_GetEntry(t *testing.T) {
 	for _, tt := range sqlTestCases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -48,10 +60,20 @@ func TestSQLSource_UpdateEntry(t *testing.T) {
 	}
 }
 
+func TestSQLSource_VersionConflict(t *testing.T) {
+	for _, tt := range sqlTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, sq := tt.src(t)
+
+			testDataSourceVersionConflict(t, sq, entries)
+		})
+	}
+}
+
 func TestSQLSource_DeleteEntry(t *testing.T) {
 	testCases := []struct {
 		name    string
-		entryID uint16
+		entryID uint64
 		wantErr error
 	}{
 		{"Delete first entry", 1, nil},
@@ -81,6 +103,114 @@ func TestSQLSource_DeleteEntry(t *testing.T) {
 	}
 }
 
+func TestSQLSource_SoftDelete(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, sq := sqlTest.src(t)
+
+			testDataSourceSoftDelete(t, sq, entries)
+		})
+	}
+}
+
+func TestSQLSource_ApplyChanges(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, sq := sqlTest.src(t)
+
+			testDataSourceApplyChanges(t, sq, entries)
+		})
+	}
+}
+
+func TestSQLSource_Proposals(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, sq := sqlTest.src(t)
+
+			testDataSourceProposals(t, sq, entries)
+		})
+	}
+}
+
+func TestSQLSource_LargeProposalIDs(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, ds := sqlTest.src(t)
+			sq := sqlSourceOf(t, ds)
+
+			const largeID = 1 << 40
+
+			proposal := NewProposal(OpAdd, "alice", nil, entries[0])
+
+			if err := sq.AddProposal(proposal); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := sq.exec(fmt.Sprintf("UPDATE %s SET id = ? WHERE id = ?", sq.proposalsTableName()), largeID,
+				proposal.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := sq.GetProposal(largeID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(stored.Proposer, ShouldEqual, proposal.Proposer); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSQLSource_LargeSnapshotNumbers(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, ds := sqlTest.src(t)
+			sq := sqlSourceOf(t, ds)
+
+			const largeNumber = 1 << 40
+
+			snapshot, err := sq.PublishSnapshot("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for table, column := range map[string]string{
+				sq.snapshotsTableName():       "id",
+				sq.snapshotEntriesTableName(): "snapshot_id",
+			} {
+				_, err = sq.exec(fmt.Sprintf("UPDATE %[1]s SET %[2]s = ? WHERE %[2]s = ?", table, column), largeNumber,
+					snapshot.Number)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stored, err := sq.GetSnapshot(largeNumber)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ok, err := So(stored.Entries, ShouldHaveLength, len(entries)); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestSQLSource_Snapshots(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, sq := sqlTest.src(t)
+
+			testDataSourceSnapshots(t, sq, entries)
+		})
+	}
+}
+
 func TestSQLSource_AddEntry(t *testing.T) {
 	for _, sqlTest := range sqlTestCases {
 		t.Run(sqlTest.name, func(t *testing.T) {
@@ -91,6 +221,16 @@ func TestSQLSource_AddEntry(t *testing.T) {
 	}
 }
 
+func TestSQLSource_History(t *testing.T) {
+	for _, sqlTest := range sqlTestCases {
+		t.Run(sqlTest.name, func(t *testing.T) {
+			entries, sq := sqlTest.src(t)
+
+			testDataSourceHistory(t, sq, entries)
+		})
+	}
+}
+
 func TestSQLiteSource_WriteEntries(t *testing.T) {
 	entries := createTestEntries(t)
 
@@ -113,7 +253,7 @@ func TestSQLiteSource_WriteEntries(t *testing.T) {
 	}
 
 	for i, entry := range entries {
-		ok, err := So(entry.ID, ShouldEqual, uint16(i+1))
+		ok, err := So(entry.ID, ShouldEqual, uint64(i+1))
 		if !ok {
 			t.Error(err)
 		}
@@ -154,7 +294,7 @@ func TestMySQLSource_WriteEntries(t *testing.T) {
 	}
 
 	for i, entry := range entries {
-		ok, err := So(entry.ID, ShouldEqual, uint16(i+1))
+		ok, err := So(entry.ID, ShouldEqual, uint64(i+1))
 		if !ok {
 			t.Error(err)
 		}
@@ -183,6 +323,139 @@ func TestSQLiteSource_CreateTable(t *testing.T) {
 	if ok, err := So(tableNames, ShouldContain, DefaultTableName); !ok {
 		log.Fatal(err)
 	}
+
+	if ok, err := So(tableNames, ShouldContain, DefaultTableName+historyTableSuffix); !ok {
+		log.Fatal(err)
+	}
+
+	if ok, err := So(tableNames, ShouldContain, DefaultTableName+proposalsTableSuffix); !ok {
+		log.Fatal(err)
+	}
+
+	if ok, err := So(tableNames, ShouldContain, DefaultTableName+snapshotEntriesTableSuffix); !ok {
+		log.Fatal(err)
+	}
+}
+
+func TestSQLiteSource_CreateTableAddsVersion(t *testing.T) {
+	sq, err := NewSQLiteSource(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer callAndLogError(t, sq.Close)
+
+	_, err = sq.db.Exec(`CREATE TABLE entries (id INTEGER PRIMARY KEY AUTOINCREMENT, reporting_name TEXT,
+		reporting_root TEXT, directory TEXT, instruction TEXT, keep TEXT, skip TEXT, requestor TEXT, faculty TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sq.db.Exec(`INSERT INTO entries (reporting_name, reporting_root, directory, instruction, keep, skip,
+		requestor, faculty) VALUES ('legacy', '/a', '/a/b', 'backup', '', '', 'user', 'group')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sq.CreateTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := sq.GetEntry(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(entry.Version, ShouldEqual, 0); !ok {
+		t.Error(err)
+	}
+}
+
+func TestSQLiteSource_LargeIDs(t *testing.T) {
+	entries, sq := createTestSQLiteTable(t)
+	defer callAndLogError(t, sq.Close)
+
+	const largeID = 1 << 40
+
+	_, err := sq.db.Exec(`UPDATE entries SET id = ? WHERE id = ?`, largeID, entries[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEntry := *entries[0]
+
+	err = sq.AddEntry(&newEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(newEntry.ID, ShouldEqual, uint64(largeID+1)); !ok {
+		t.Fatal(err)
+	}
+
+	newEntry.Requestor = "another_user"
+
+	err = sq.UpdateEntry(&newEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := sq.GetEntry(largeID + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(stored, ShouldResemble, &newEntry); !ok {
+		t.Error(err)
+	}
+}
+
+func TestSQLiteSource_ReorderedAndExtraColumns(t *testing.T) {
+	sq, err := NewSQLiteSource(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer callAndLogError(t, sq.Close)
+
+	err = sq.CreateTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sq.db.Exec(`DROP TABLE entries`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sq.db.Exec(`CREATE TABLE entries (audit_user TEXT DEFAULT 'dba', faculty TEXT, requestor TEXT,
+		"ignore" TEXT, "match" TEXT, version INTEGER NOT NULL DEFAULT 0, instruction TEXT, directory TEXT,
+		deleted_at VARCHAR(32), reporting_root TEXT, reporting_name TEXT, id INTEGER PRIMARY KEY AUTOINCREMENT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := createTestEntries(t)
+
+	err = sq.WriteEntries(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries[1].Ignore = "*.log"
+
+	err = sq.UpdateEntry(entries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEntries, err := sq.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(newEntries, ShouldResemble, entries); !ok {
+		t.Error(err)
+	}
 }
 
 func TestMySQLSource_CreateTable(t *testing.T) {
@@ -221,6 +494,31 @@ func TestMySQLSource_CreateTable(t *testing.T) {
 	}
 }
 
+func TestPostgresSource_CreateTable(t *testing.T) {
+	tableName := "test_create_table"
+
+	sq := newTestPostgresSource(t, tableName)
+
+	err := sq.CreateTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupSQL(t, sq.SQLSource)
+
+	tableNames, err := sq.ShowTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(tableNames, ShouldContain, tableName); !ok {
+		t.Fatal(err)
+	}
+
+	if ok, err := So(tableNames, ShouldContain, tableName+historyTableSuffix); !ok {
+		t.Fatal(err)
+	}
+}
+
 // createTestSQLiteTable initialises a test SQLite database, creates a table, inserts test entries, and returns them and
 // SQLite source. You should close the database connection with sq.Close() once it no longer needed.
 func createTestSQLiteTable(t *testing.T) ([]*Entry, SQLiteSource) {
@@ -251,6 +549,24 @@ func createTestSQLiteTable(t *testing.T) ([]*Entry, SQLiteSource) {
 	return entries, sq
 }
 
+// sqlSourceOf returns the SQLSource behind a data source made for the tests.
+func sqlSourceOf(t *testing.T, ds DataSource) *SQLSource {
+	t.Helper()
+
+	switch s := ds.(type) {
+	case SQLiteSource:
+		return s.SQLSource
+	case MySQLSource:
+		return s.SQLSource
+	case PostgresSource:
+		return s.SQLSource
+	}
+
+	t.Fatalf("%T is not a SQL source", ds)
+
+	return nil
+}
+
 func setupSQLiteSourceForTest(t *testing.T) ([]*Entry, DataSource) {
 	t.Helper()
 
@@ -323,7 +639,83 @@ func setupMySQLSourceForTest(t *testing.T) ([]*Entry, DataSource) {
 func cleanupMySQL(t *testing.T, sq MySQLSource) {
 	t.Helper()
 
+	cleanupSQL(t, sq.SQLSource)
+}
+
+// createTestPostgresTable initialises a connection to a PostgreSQL database, creates a table, inserts test entries,
+// and returns them and PostgreSQL source. You should close the database connection with sq.Close() once it no longer
+// needed.
+func createTestPostgresTable(t *testing.T) ([]*Entry, PostgresSource) {
+	t.Helper()
+
+	entries := createTestEntries(t)
+	for _, entry := range entries {
+		entry.ID += 1
+	}
+
+	sq := newTestPostgresSource(t, "entries_test")
+
+	err := sq.CreateTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sq.WriteEntries(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries, sq
+}
+
+// newTestPostgresSource connects to the database given by the PG* environment variables, skipping the test if they
+// are not set.
+func newTestPostgresSource(t *testing.T, tableName string) PostgresSource {
+	t.Helper()
+
+	sq, err := NewPostgresSource(
+		os.Getenv("PGHOST"),
+		os.Getenv("PGPORT"),
+		os.Getenv("PGUSER"),
+		os.Getenv("PGPASSWORD"),
+		os.Getenv("PGDATABASE"),
+		tableName,
+	)
+	if err != nil {
+		if errors.Is(err, ErrMissingArgument) {
+			t.Skip("Skipping PostgreSQL test because PostgreSQL host, port, user, or database is not set.")
+		}
+
+		t.Fatal(err)
+	}
+
+	return sq
+}
+
+func setupPostgresSourceForTest(t *testing.T) ([]*Entry, DataSource) {
+	t.Helper()
+
+	entries, sq := createTestPostgresTable(t)
+
+	t.Cleanup(func() {
+		cleanupSQL(t, sq.SQLSource)
+	})
+
+	return entries, sq
+}
+
+func cleanupSQL(t *testing.T, sq *SQLSource) {
+	t.Helper()
+
 	callAndLogError(t, sq.DropTable)
+
+	for _, table := range []string{sq.historyTableName(), sq.proposalsTableName(), sq.snapshotsTableName(),
+		sq.snapshotEntriesTableName()} {
+		if _, err := sq.exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table)); err != nil {
+			t.Log(err)
+		}
+	}
+
 	callAndLogError(t, sq.Close)
 }
 
@@ -29,7 +29,7 @@ func createTestEntries(t *testing.T) []*Entry {
 	for i := range NumTestDataRows {
 		newEntry := baseEntry
 		newEntry.ReportingName = fmt.Sprintf("test_project_%d", i)
-		newEntry.ID = uint16(i)
+		newEntry.ID = uint64(i)
 
 		entries[i] = &newEntry
 	}
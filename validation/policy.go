@@ -0,0 +1,240 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"backup-plan-ui/sources"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy holds the site specific rules entries must follow, on top of those
+// every entry must follow. Policies come from LoadPolicy or DefaultPolicy, and
+// a nil *Policy is the DefaultPolicy.
+type Policy struct {
+	// Required fields cannot be blank.
+	Required []Field `yaml:"required"`
+
+	// Instructions are the instructions entries may have.
+	Instructions []sources.Instruction `yaml:"instructions"`
+
+	// MinDepth is the least number of directories a reporting root must have,
+	// unless its Filesystem says otherwise.
+	MinDepth int `yaml:"min_depth"`
+
+	// Filesystems, if any, are the only places reporting roots may be.
+	Filesystems []Filesystem `yaml:"filesystems"`
+
+	// Formats are regular expressions the ReportingName, Requestor or Faculty
+	// of entries must match.
+	Formats map[Field]Format `yaml:"formats"`
+}
+
+// Filesystem is a directory reporting roots may be in, with its own minimum
+// depth for them.
+type Filesystem struct {
+	Prefix   string `yaml:"prefix"`
+	MinDepth int    `yaml:"min_depth"`
+}
+
+// Format is a regular expression a field must match, with a description of it
+// for error messages, e.g. "a user id".
+type Format struct {
+	Regex       string `yaml:"regex"`
+	Description string `yaml:"description"`
+
+	re *regexp.Regexp
+}
+
+const defaultMinDepth = 5
+
+var (
+	ErrUnknownField       = errors.New("unknown field")
+	ErrUnknownInstruction = errors.New("unknown instruction")
+	ErrRelativePrefix     = errors.New("filesystem prefix must be absolute")
+
+	// formatFields are the fields a Policy may give a Format.
+	formatFields = []Field{ReportingName, Requestor, Faculty}
+
+	allFields = []Field{ReportingName, ReportingRoot, Directory, Instruction, Match, Ignore, Requestor, Faculty}
+)
+
+// DefaultPolicy returns the policy used without a policy file: every field but
+// Match and Ignore is required, every instruction is allowed, and reporting
+// roots must be at least five directories deep.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Required:     []Field{ReportingName, ReportingRoot, Directory, Instruction, Requestor, Faculty},
+		Instructions: []sources.Instruction{sources.Backup, sources.NoBackup, sources.TempBackup},
+		MinDepth:     defaultMinDepth,
+	}
+}
+
+// LoadPolicy reads a Policy from a YAML file, such as:
+//
+//	required: [ReportingName, ReportingRoot, Directory, Instruction, Requestor, Faculty]
+//	instructions: [backup, nobackup, tempbackup]
+//	filesystems:
+//	  - prefix: /lustre
+//	    min_depth: 5
+//	  - prefix: /nfs
+//	    min_depth: 3
+//	formats:
+//	  Requestor:
+//	    regex: '^[a-z][a-z0-9]*$'
+//	    description: a user id
+//
+// Settings left out of the file are those of the DefaultPolicy.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var policy Policy
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err = dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid validation policy %s: %w", path, err)
+	}
+
+	if err = policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid validation policy %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+// compile fills in the defaults, checks the policy makes sense and compiles its
+// regular expressions.
+func (p *Policy) compile() error {
+	defaults := DefaultPolicy()
+
+	if p.Required == nil {
+		p.Required = defaults.Required
+	}
+
+	if p.Instructions == nil {
+		p.Instructions = defaults.Instructions
+	}
+
+	if p.MinDepth == 0 {
+		p.MinDepth = defaults.MinDepth
+	}
+
+	for _, field := range p.Required {
+		if !slices.Contains(allFields, field) {
+			return fmt.Errorf("required: %w %q", ErrUnknownField, field)
+		}
+	}
+
+	for _, instruction := range p.Instructions {
+		if !slices.Contains(defaults.Instructions, instruction) {
+			return fmt.Errorf("instructions: %w %q", ErrUnknownInstruction, instruction)
+		}
+	}
+
+	for i, fs := range p.Filesystems {
+		if !path.IsAbs(fs.Prefix) {
+			return fmt.Errorf("filesystems: %w: %q", ErrRelativePrefix, fs.Prefix)
+		}
+
+		p.Filesystems[i].Prefix = path.Clean(fs.Prefix)
+	}
+
+	for field, format := range p.Formats {
+		if !slices.Contains(formatFields, field) {
+			return fmt.Errorf("formats: %w %q", ErrUnknownField, field)
+		}
+
+		re, err := regexp.Compile(format.Regex)
+		if err != nil {
+			return fmt.Errorf("formats: %s: %w", field, err)
+		}
+
+		format.re = re
+		p.Formats[field] = format
+	}
+
+	return nil
+}
+
+// orDefault returns the policy, or the DefaultPolicy if it is nil.
+func (p *Policy) orDefault() *Policy {
+	if p == nil {
+		return DefaultPolicy()
+	}
+
+	return p
+}
+
+// filesystemFor returns the filesystem with the longest prefix containing the
+// reporting root, or nil if there is none.
+func (p *Policy) filesystemFor(root string) *Filesystem {
+	var best *Filesystem
+
+	for i, fs := range p.Filesystems {
+		if (fs.Prefix == "/" || root == fs.Prefix || strings.HasPrefix(root, fs.Prefix+"/")) &&
+			(best == nil || len(fs.Prefix) > len(best.Prefix)) {
+			best = &p.Filesystems[i]
+		}
+	}
+
+	return best
+}
+
+// minDepthFor returns the least number of directories the reporting root of the
+// given filesystem, which may be nil, must have.
+func (p *Policy) minDepthFor(fs *Filesystem) int {
+	if fs != nil && fs.MinDepth > 0 {
+		return fs.MinDepth
+	}
+
+	return p.MinDepth
+}
+
+func (p *Policy) instructionError() string {
+	names := make([]string, len(p.Instructions))
+
+	for i, instruction := range p.Instructions {
+		names[i] = string(instruction)
+	}
+
+	return fmt.Sprintf(ErrInvalidInstruction, orList(names))
+}
+
+func (p *Policy) rootNotAllowedError() string {
+	prefixes := make([]string, len(p.Filesystems))
+
+	for i, fs := range p.Filesystems {
+		prefixes[i] = fs.Prefix
+	}
+
+	return fmt.Sprintf(ErrRootNotAllowed, orList(prefixes))
+}
+
+func (f Format) error() string {
+	if f.Description != "" {
+		return fmt.Sprintf(ErrInvalidFormat, f.Description)
+	}
+
+	return fmt.Sprintf(ErrFormatMismatch, f.Regex)
+}
+
+// orList joins the items as in "a, b or c".
+func orList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
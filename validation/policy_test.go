@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+const testPolicy = `
+required: [ReportingName, ReportingRoot, Directory, Instruction, Faculty]
+instructions: [backup, nobackup]
+filesystems:
+  - prefix: /lustre
+  - prefix: /nfs/
+    min_depth: 3
+formats:
+  Requestor:
+    regex: '^[a-z][a-z0-9]*$'
+    description: a user id
+  Faculty:
+    regex: '^[a-z]+$'
+`
+
+func writePolicy(t *testing.T, policy string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.yml")
+
+	if err := os.WriteFile(path, []byte(policy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		change   func(*sources.Entry)
+		expected Errors
+	}{
+		{"Valid entries have no errors", func(*sources.Entry) {}, Errors{}},
+		{"Fields left out of required may be blank", func(e *sources.Entry) { e.Requestor = "" }, Errors{}},
+		{"Only the listed instructions are allowed", func(e *sources.Entry) {
+			e.Instruction, e.Match = sources.TempBackup, ""
+		}, Errors{Instruction: "Input must be backup or nobackup"}},
+		{"Roots must be on a filesystem", func(e *sources.Entry) {
+			e.ReportingRoot, e.Directory = "/a/b/c/d/e", "/a/b/c/d/e"
+		}, Errors{ReportingRoot: "Reporting Root must be inside /lustre or /nfs"}},
+		{"Filesystems have their own depth", func(e *sources.Entry) {
+			e.ReportingRoot, e.Directory = "/nfs/a/b", "/nfs/a/b/c"
+		}, Errors{}},
+		{"Filesystems have the default depth otherwise", func(e *sources.Entry) {
+			e.ReportingRoot, e.Directory = "/lustre/a/b", "/lustre/a/b/c"
+		}, Errors{ReportingRoot: "Reporting Root must be at least 5 levels deep"}},
+		{"Formats are described", func(e *sources.Entry) { e.Requestor = "User 1" },
+			Errors{Requestor: "Must be a user id"}},
+		{"Formats without a description show the regex", func(e *sources.Entry) { e.Faculty = "hgi2" },
+			Errors{Faculty: "Must match ^[a-z]+$"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := validEntry()
+			entry.ReportingRoot = "/lustre/scratch1/humgen/projects/x"
+			entry.Directory = "/lustre/scratch1/humgen/projects/x/input"
+			entry.Requestor = "user1"
+			entry.Faculty = "hgi"
+			tt.change(entry)
+
+			if ok, err := So(policy.Validate(entry), ShouldResemble, tt.expected); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   string
+		expected error
+	}{
+		{"Unknown required fields", "required: [Colour]", ErrUnknownField},
+		{"Unknown instructions", "instructions: [archive]", ErrUnknownInstruction},
+		{"Relative prefixes", "filesystems: [{prefix: lustre}]", ErrRelativePrefix},
+		{"Formats for other fields", "formats: {Directory: {regex: '.*'}}", ErrUnknownField},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadPolicy(writePolicy(t, tt.policy))
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	t.Run("Invalid regular expressions", func(t *testing.T) {
+		if _, err := LoadPolicy(writePolicy(t, "formats: {Faculty: {regex: '('}}")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestNilPolicy(t *testing.T) {
+	var policy *Policy
+
+	if ok, err := So(policy.Validate(validEntry()), ShouldBeEmpty); !ok {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package validation holds the rules every entry of the plan must follow,
+// wherever it comes from: the web interface, the API or an imported CSV file.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"backup-plan-ui/resolver"
+	"backup-plan-ui/sources"
+)
+
+// Field names a field of an Entry.
+type Field string
+
+const (
+	ReportingName Field = "ReportingName"
+	ReportingRoot Field = "ReportingRoot"
+	Directory     Field = "Directory"
+	Instruction   Field = "Instruction"
+	Match         Field = "Match"
+	Ignore        Field = "Ignore"
+	Requestor     Field = "Requestor"
+	Faculty       Field = "Faculty"
+)
+
+// The messages for invalid fields. Those with verbs are formatted with the
+// details of the Policy that the field breaks.
+const (
+	ErrBlankInput                 = "You cannot leave this field blank"
+	ErrInvalidInstruction         = "Input must be %s"
+	ErrIgnoreWithoutBackup        = "Ignore can only be used with the backup instruction"
+	ErrMatchWithoutBackup         = "Match can only be used with the backup instruction"
+	ErrInvalidPattern             = "Invalid pattern "
+	ErrDirectoryNotInRoot         = "Directory must be inside Reporting root"
+	ErrReportingRootNotDeepEnough = "Reporting Root must be at least %d levels deep"
+	ErrRootWithoutSlash           = "Reporting Root must start with a slash (/)"
+	ErrRootNotAllowed             = "Reporting Root must be inside %s"
+	ErrInvalidFormat              = "Must be %s"
+	ErrFormatMismatch             = "Must match %s"
+)
+
+var ErrInvalidEntry = errors.New("invalid entry")
+
+// Errors maps each invalid field of an entry to the first problem found with it.
+type Errors map[Field]string
+
+// EntryError is returned for an entry that breaks the rules.
+type EntryError struct {
+	Entry  *sources.Entry
+	Fields Errors
+}
+
+func (e *EntryError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+
+	for field := range e.Fields {
+		fields = append(fields, string(field))
+	}
+
+	slices.Sort(fields)
+
+	problems := make([]string, len(fields))
+
+	for i, field := range fields {
+		problems[i] = fmt.Sprintf("%s: %s", field, e.Fields[Field(field)])
+	}
+
+	return fmt.Sprintf("%s %d (%s): %s", ErrInvalidEntry, e.Entry.ID, e.Entry.Directory,
+		strings.Join(problems, "; "))
+}
+
+func (e *EntryError) Unwrap() error {
+	return ErrInvalidEntry
+}
+
+// Validate returns the problems with the fields of the entry under the policy,
+// which is empty if the entry is valid.
+func (p *Policy) Validate(entry *sources.Entry) Errors {
+	p = p.orDefault()
+	errs := make(Errors)
+
+	errs.checkRequired(p, entry)
+	errs.checkInstruction(p, entry)
+	errs.checkPatterns(Match, entry.Match)
+	errs.checkPatterns(Ignore, entry.Ignore)
+	errs.checkDirectoryAndRoot(p, entry)
+	errs.checkFormats(p, entry)
+
+	return errs
+}
+
+// Entry returns an *EntryError if the entry breaks the policy, and nil
+// otherwise.
+func (p *Policy) Entry(entry *sources.Entry) error {
+	if errs := p.Validate(entry); len(errs) > 0 {
+		return &EntryError{Entry: entry, Fields: errs}
+	}
+
+	return nil
+}
+
+// Plan checks every entry of a plan, returning the *EntryError of each invalid
+// one joined together, or nil if they are all valid.
+func (p *Policy) Plan(entries []*sources.Entry) error {
+	var errs []error
+
+	for _, entry := range entries {
+		if err := p.Entry(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (errs Errors) add(field Field, err string) {
+	if _, exists := errs[field]; !exists {
+		errs[field] = err
+	}
+}
+
+func (errs Errors) checkRequired(p *Policy, entry *sources.Entry) {
+	for _, field := range p.Required {
+		if fieldValue(entry, field) == "" {
+			errs[field] = ErrBlankInput
+		}
+	}
+}
+
+func (errs Errors) checkInstruction(p *Policy, entry *sources.Entry) {
+	instr := entry.Instruction
+
+	if !slices.Contains(p.Instructions, instr) {
+		errs.add(Instruction, p.instructionError())
+	}
+
+	if entry.Ignore != "" && instr != sources.Backup {
+		errs.add(Ignore, ErrIgnoreWithoutBackup)
+	}
+
+	if entry.Match != "" && instr != sources.Backup {
+		errs.add(Match, ErrMatchWithoutBackup)
+	}
+}
+
+// checkPatterns rejects the field if any of its space separated patterns is not
+// a valid glob, naming each one that is not.
+func (errs Errors) checkPatterns(field Field, patterns string) {
+	patternErrs := resolver.CheckPatterns(resolver.SplitPatterns(patterns))
+	if len(patternErrs) == 0 {
+		return
+	}
+
+	msgs := make([]string, len(patternErrs))
+
+	for i, err := range patternErrs {
+		msgs[i] = ErrInvalidPattern + err.Error()
+	}
+
+	errs.add(field, strings.Join(msgs, "; "))
+}
+
+func (errs Errors) checkDirectoryAndRoot(p *Policy, entry *sources.Entry) {
+	reportingRoot := entry.ReportingRoot
+	dir := entry.Directory
+
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	if !strings.HasPrefix(reportingRoot, "/") {
+		errs.add(ReportingRoot, ErrRootWithoutSlash)
+	}
+
+	rel, err := filepath.Rel(reportingRoot, dir)
+	if err != nil || strings.HasPrefix(rel, "../") || rel == ".." {
+		errs.add(Directory, ErrDirectoryNotInRoot)
+	}
+
+	fs := p.filesystemFor(filepath.Clean(reportingRoot))
+	if fs == nil && len(p.Filesystems) > 0 {
+		errs.add(ReportingRoot, p.rootNotAllowedError())
+	}
+
+	depth := 0
+	for _, part := range strings.Split(reportingRoot, string(filepath.Separator)) {
+		if part != "" {
+			depth++
+		}
+	}
+
+	if minDepth := p.minDepthFor(fs); depth < minDepth {
+		errs.add(ReportingRoot, fmt.Sprintf(ErrReportingRootNotDeepEnough, minDepth))
+	}
+}
+
+func (errs Errors) checkFormats(p *Policy, entry *sources.Entry) {
+	for field, format := range p.Formats {
+		if value := fieldValue(entry, field); value != "" && !format.re.MatchString(value) {
+			errs.add(field, format.error())
+		}
+	}
+}
+
+// fieldValue returns the value of the named field of the entry.
+func fieldValue(entry *sources.Entry, field Field) string {
+	switch field {
+	case ReportingName:
+		return entry.ReportingName
+	case ReportingRoot:
+		return entry.ReportingRoot
+	case Directory:
+		return entry.Directory
+	case Instruction:
+		return string(entry.Instruction)
+	case Match:
+		return entry.Match
+	case Ignore:
+		return entry.Ignore
+	case Requestor:
+		return entry.Requestor
+	case Faculty:
+		return entry.Faculty
+	default:
+		return ""
+	}
+}
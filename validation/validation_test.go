@@ -0,0 +1,84 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"backup-plan-ui/sources"
+
+	. "github.com/smarty/assertions"
+)
+
+func validEntry() *sources.Entry {
+	return &sources.Entry{
+		ID:            1,
+		ReportingName: "test_report",
+		ReportingRoot: "/a/b/c/d/e",
+		Directory:     "/a/b/c/d/e/f",
+		Instruction:   sources.Backup,
+		Match:         "*.cram",
+		Requestor:     "test_user",
+		Faculty:       "test_group",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(*sources.Entry)
+		expected Errors
+	}{
+		{"Valid entries have no errors", func(*sources.Entry) {}, Errors{}},
+		{"Blank fields", func(e *sources.Entry) { e.Requestor = "" }, Errors{Requestor: ErrBlankInput}},
+		{"Unknown instructions", func(e *sources.Entry) { e.Instruction = "keep" },
+			Errors{Instruction: "Input must be backup, nobackup or tempbackup", Match: ErrMatchWithoutBackup}},
+		{"Patterns without backup", func(e *sources.Entry) { e.Instruction, e.Ignore = sources.NoBackup, "*.log" },
+			Errors{Match: ErrMatchWithoutBackup, Ignore: ErrIgnoreWithoutBackup}},
+		{"Malformed patterns", func(e *sources.Entry) { e.Ignore = "[x" },
+			Errors{Ignore: ErrInvalidPattern + `"[x": syntax error in pattern`}},
+		{"Directories outside the reporting root", func(e *sources.Entry) { e.Directory = "/a/b/c/d/x" },
+			Errors{Directory: ErrDirectoryNotInRoot}},
+		{"Shallow reporting roots", func(e *sources.Entry) { e.ReportingRoot, e.Directory = "a/b", "a/b/c" },
+			Errors{ReportingRoot: ErrRootWithoutSlash}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := validEntry()
+			tt.change(entry)
+
+			if ok, err := So(DefaultPolicy().Validate(entry), ShouldResemble, tt.expected); !ok {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	valid := validEntry()
+	invalid := validEntry()
+	invalid.ID = 2
+	invalid.Faculty = ""
+
+	if err := DefaultPolicy().Plan([]*sources.Entry{valid}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := DefaultPolicy().Plan([]*sources.Entry{valid, invalid})
+	if !errors.Is(err, ErrInvalidEntry) {
+		t.Fatalf("expected %v, got %v", ErrInvalidEntry, err)
+	}
+
+	var entryErr *EntryError
+	if !errors.As(err, &entryErr) {
+		t.Fatalf("expected an EntryError, got %v", err)
+	}
+
+	if ok, err := So(entryErr.Entry, ShouldEqual, invalid); !ok {
+		t.Error(err)
+	}
+
+	if ok, err := So(err.Error(), ShouldEqual, "invalid entry 2 (/a/b/c/d/e/f): Faculty: "+ErrBlankInput); !ok {
+		t.Error(err)
+	}
+}